@@ -0,0 +1,169 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultRetryBudgetRatio specifies part of requests that can be retried
+	DefaultRetryBudgetRatio = 0.2
+	// DefaultRetryBudgetWindow specifies sliding window of requests and retries counted by budget
+	DefaultRetryBudgetWindow = 10 * time.Second
+	// DefaultRetryBudgetMinPerSecond specifies retries allowed per second regardless of requests
+	DefaultRetryBudgetMinPerSecond = 10
+)
+
+// budgetSlots specifies number of slots the budget window split into
+const budgetSlots = 10
+
+// RetryBudget limits retries to part of requests, so retries do not amplify load during outage
+type RetryBudget interface {
+	// Request counts the request
+	Request(req Request)
+	// Retry counts the retry, false returned and retry not counted if budget exhausted
+	Retry(req Request) bool
+}
+
+// RetryBudgetOptions holds retry budget options
+type RetryBudgetOptions struct {
+	// Ratio specifies part of requests that can be retried
+	Ratio float64
+	// Window specifies sliding window of requests and retries counted by budget
+	Window time.Duration
+	// MinPerSecond specifies retries allowed per second regardless of requests
+	MinPerSecond int
+	// PerService creates separate budget for each service
+	PerService bool
+}
+
+// RetryBudgetOption func signature
+type RetryBudgetOption func(*RetryBudgetOptions)
+
+// RetryBudgetRatio sets part of requests that can be retried, 0.2 allows 20% retries
+func RetryBudgetRatio(r float64) RetryBudgetOption {
+	return func(o *RetryBudgetOptions) {
+		o.Ratio = r
+	}
+}
+
+// RetryBudgetWindow sets sliding window of requests and retries counted by budget
+func RetryBudgetWindow(d time.Duration) RetryBudgetOption {
+	return func(o *RetryBudgetOptions) {
+		o.Window = d
+	}
+}
+
+// RetryBudgetMinPerSecond sets retries allowed per second regardless of requests
+func RetryBudgetMinPerSecond(n int) RetryBudgetOption {
+	return func(o *RetryBudgetOptions) {
+		o.MinPerSecond = n
+	}
+}
+
+// RetryBudgetPerService creates separate budget for each service instead of one budget per client
+func RetryBudgetPerService(b bool) RetryBudgetOption {
+	return func(o *RetryBudgetOptions) {
+		o.PerService = b
+	}
+}
+
+// NewRetryBudgetOptions returns new RetryBudgetOptions filled by passed RetryBudgetOption
+func NewRetryBudgetOptions(opts ...RetryBudgetOption) RetryBudgetOptions {
+	options := RetryBudgetOptions{
+		Ratio:        DefaultRetryBudgetRatio,
+		Window:       DefaultRetryBudgetWindow,
+		MinPerSecond: DefaultRetryBudgetMinPerSecond,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// budgetSlot counts requests and retries started in the slot
+type budgetSlot struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// budgetWindow holds slots of the sliding window
+type budgetWindow struct {
+	slots [budgetSlots]budgetSlot
+}
+
+type retryBudget struct {
+	windows map[string]*budgetWindow
+	opts    RetryBudgetOptions
+	sync.Mutex
+}
+
+// NewRetryBudget returns retry budget that counts requests and retries in sliding Window and allows retry
+// while retries do not exceed Ratio of requests plus MinPerSecond for each second of Window.
+// Budget passed to client via SharedRetryBudget or WithRetryBudget.
+func NewRetryBudget(opts ...RetryBudgetOption) RetryBudget {
+	options := NewRetryBudgetOptions(opts...)
+	if options.Window < budgetSlots {
+		options.Window = DefaultRetryBudgetWindow
+	}
+	return &retryBudget{windows: make(map[string]*budgetWindow), opts: options}
+}
+
+func (b *retryBudget) Request(req Request) {
+	b.Lock()
+	b.slot(req, time.Now()).requests++
+	b.Unlock()
+}
+
+func (b *retryBudget) Retry(req Request) bool {
+	now := time.Now()
+
+	b.Lock()
+	defer b.Unlock()
+
+	slot := b.slot(req, now)
+	w := b.windows[b.key(req)]
+
+	var requests, retries int
+	for _, s := range w.slots {
+		if now.Sub(s.start) < b.opts.Window {
+			requests += s.requests
+			retries += s.retries
+		}
+	}
+
+	allowed := b.opts.Ratio*float64(requests) + float64(b.opts.MinPerSecond)*b.opts.Window.Seconds()
+	if float64(retries)+1 > allowed {
+		return false
+	}
+	slot.retries++
+
+	return true
+}
+
+func (b *retryBudget) key(req Request) string {
+	if b.opts.PerService {
+		return req.Service()
+	}
+	return ""
+}
+
+// slot returns current slot of the request window, expired slot reset, must be called with lock held
+func (b *retryBudget) slot(req Request, now time.Time) *budgetSlot {
+	key := b.key(req)
+	w, ok := b.windows[key]
+	if !ok {
+		w = &budgetWindow{}
+		b.windows[key] = w
+	}
+
+	size := b.opts.Window / budgetSlots
+	start := now.Truncate(size)
+	s := &w.slots[int(start.UnixNano()/int64(size))%budgetSlots]
+	if !s.start.Equal(start) {
+		*s = budgetSlot{start: start}
+	}
+
+	return s
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/metadata"
+)
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(RetryBudgetRatio(0.5), RetryBudgetMinPerSecond(0), RetryBudgetPerService(true))
+	req := &testRequest{service: "test"}
+
+	for i := 0; i < 4; i++ {
+		b.Request(req)
+	}
+	for i := 0; i < 2; i++ {
+		if !b.Retry(req) {
+			t.Fatalf("retry %d must be allowed", i)
+		}
+	}
+	if b.Retry(req) {
+		t.Fatal("retry must be rejected by exhausted budget")
+	}
+	if b.Retry(&testRequest{service: "other"}) {
+		t.Fatal("other service budget must be empty")
+	}
+}
+
+func newTestRetryClient(err error, opts ...Option) (Client, func() int) {
+	var mu sync.Mutex
+	var calls int
+	wrapper := func(CallFunc) CallFunc {
+		return func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			return err
+		}
+	}
+
+	c := NewClient(append([]Option{WrapCall(wrapper), Retries(3), Retry(RetryAlways)}, opts...)...)
+	return c, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func TestRetryBudgetCall(t *testing.T) {
+	b := NewRetryBudget(RetryBudgetRatio(0), RetryBudgetMinPerSecond(0))
+	c, calls := newTestRetryClient(errors.InternalServerError("test", "error"), SharedRetryBudget(b))
+
+	req := &testRequest{service: "test"}
+	if err := c.Call(context.TODO(), req, nil, WithAddress("127.0.0.1:1")); err == nil {
+		t.Fatal("call must fail")
+	}
+	if n := calls(); n != 1 {
+		t.Fatalf("call must not be retried with exhausted budget, calls %d", n)
+	}
+}
+
+func TestRetryAfterCall(t *testing.T) {
+	err := &errors.Error{ID: "test", Code: 503, Metadata: metadata.Metadata{metadata.HeaderRetryAfter: "60"}}
+	c, calls := newTestRetryClient(err)
+
+	ts := time.Now()
+	req := &testRequest{service: "test"}
+	if cerr := c.Call(context.TODO(), req, nil, WithAddress("127.0.0.1:1"), WithRequestTimeout(time.Second)); cerr == nil {
+		t.Fatal("call must fail")
+	}
+	// retry after exceeds deadline, so call not retried
+	if n := calls(); n != 1 || time.Since(ts) > 500*time.Millisecond {
+		t.Fatalf("call must fail without retry, calls %d", n)
+	}
+}
+
+func TestRetryAfterSkipBackoff(t *testing.T) {
+	err := &errors.Error{ID: "test", Code: 503, Metadata: metadata.Metadata{metadata.HeaderRetryAfter: "1"}}
+	c, calls := newTestRetryClient(err)
+
+	var mu sync.Mutex
+	var attempts []int
+	backoff := func(ctx context.Context, req Request, attempt int) (time.Duration, error) {
+		mu.Lock()
+		attempts = append(attempts, attempt)
+		mu.Unlock()
+		return 0, nil
+	}
+
+	req := &testRequest{service: "test"}
+	if cerr := c.Call(context.TODO(), req, nil, WithAddress("127.0.0.1:1"), WithRetries(1), WithBackoff(backoff), WithRequestTimeout(5*time.Second)); cerr == nil {
+		t.Fatal("call must fail")
+	}
+	if n := calls(); n != 2 {
+		t.Fatalf("call must be retried once, calls %d", n)
+	}
+	// retry after already waited, so retry not delayed by backoff
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 1 || attempts[0] != 0 {
+		t.Fatalf("backoff must be skipped after retry after, attempts %v", attempts)
+	}
+}
+
+func TestRetryBudgetNotRetried(t *testing.T) {
+	b := NewRetryBudget(RetryBudgetRatio(0), RetryBudgetMinPerSecond(1))
+	c, calls := newTestRetryClient(errors.BadRequest("test", "error"), SharedRetryBudget(b), Retry(RetryNever))
+
+	req := &testRequest{service: "test"}
+	for i := 0; i < 3; i++ {
+		if err := c.Call(context.TODO(), req, nil, WithAddress("127.0.0.1:1")); err == nil {
+			t.Fatal("call must fail")
+		}
+	}
+	if n := calls(); n != 3 {
+		t.Fatalf("calls must not be retried, calls %d", n)
+	}
+	// errors not retried must not drain budget
+	rb := b.(*retryBudget)
+	for _, s := range rb.windows[""].slots {
+		if s.retries != 0 {
+			t.Fatalf("budget must not count retries, got %d", s.retries)
+		}
+	}
+}
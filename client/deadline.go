@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"go.unistack.org/micro/v3/metadata"
+)
+
+// deadlineContext returns context with outgoing metadata.HeaderTimeout set to time remaining until ctx deadline,
+// so server handles request within caller budget. Header updated on each attempt, because elapsed time shrinks budget.
+func deadlineContext(ctx context.Context) context.Context {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return ctx
+	}
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = metadata.Copy(md)
+	} else {
+		md = metadata.New(1)
+	}
+	md.Set(metadata.HeaderTimeout, time.Until(d).String())
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/metadata"
+)
+
+func TestCallDeadlineHeader(t *testing.T) {
+	var timeouts []time.Duration
+	wrapper := func(CallFunc) CallFunc {
+		return func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			v, ok := md.Get(metadata.HeaderTimeout)
+			if !ok {
+				t.Fatal("timeout header must be set")
+			}
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			timeouts = append(timeouts, d)
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}
+	}
+
+	c := NewClient(WrapCall(wrapper), Lookup(func(context.Context, Request, CallOptions) ([]string, error) {
+		return []string{"127.0.0.1:1"}, nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := c.Call(ctx, c.NewRequest("test", "Test.Call", nil), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if timeouts[0] > time.Second || timeouts[1] >= timeouts[0] {
+		t.Fatalf("timeout must shrink by elapsed time, got %v", timeouts)
+	}
+}
@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/register"
+	"go.unistack.org/micro/v3/selector"
+)
+
+var (
+	// DefaultHedgeSamples specifies number of latest endpoint latencies used to calculate hedge percentile
+	DefaultHedgeSamples = 100
+	// DefaultHedgeMinSamples specifies number of latencies needed before percentile used as hedge delay
+	DefaultHedgeMinSamples = 10
+	// DefaultIdempotencyTTL specifies how long endpoint idempotency looked up in register cached
+	DefaultIdempotencyTTL = time.Minute
+)
+
+// latencies holds latest successful call latencies per endpoint
+type latencies struct {
+	samples map[string][]time.Duration
+	pos     map[string]int
+	sync.Mutex
+}
+
+func newLatencies() *latencies {
+	return &latencies{samples: make(map[string][]time.Duration), pos: make(map[string]int)}
+}
+
+// record adds latency of the endpoint, oldest latency replaced if window is full
+func (l *latencies) record(key string, d time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.samples[key]) < DefaultHedgeSamples {
+		l.samples[key] = append(l.samples[key], d)
+		return
+	}
+	pos := l.pos[key] % len(l.samples[key])
+	l.samples[key][pos] = d
+	l.pos[key] = pos + 1
+}
+
+// percentile returns latency percentile of the endpoint if enough latencies recorded
+func (l *latencies) percentile(key string, p float64) (time.Duration, bool) {
+	l.Lock()
+	samples := append([]time.Duration{}, l.samples[key]...)
+	l.Unlock()
+
+	if len(samples) < DefaultHedgeMinSamples {
+		return 0, false
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	idx := int(p * float64(len(samples)))
+	if idx >= len(samples) {
+		idx = len(samples) - 1
+	}
+	return samples[idx], true
+}
+
+// hedgeDelay returns delay before hedged request, zero if call must not be hedged
+func (n *noopClient) hedgeDelay(ctx context.Context, req Request, rsp interface{}, opts CallOptions) time.Duration {
+	if opts.HedgeDelay <= 0 && opts.HedgePercentile <= 0 {
+		return 0
+	}
+	// each request decodes response to its own value
+	if t := reflect.TypeOf(rsp); t == nil || t.Kind() != reflect.Ptr {
+		return 0
+	}
+
+	delay := opts.HedgeDelay
+	if opts.HedgePercentile > 0 {
+		if d, ok := n.latency.percentile(req.Service()+"."+req.Endpoint(), opts.HedgePercentile); ok {
+			delay = d
+		}
+	}
+	if delay <= 0 || !n.idempotency.idempotent(ctx, req, opts) {
+		return 0
+	}
+
+	return delay
+}
+
+// idempotency caches endpoint idempotency looked up in register for DefaultIdempotencyTTL
+type idempotency struct {
+	reg       register.Register
+	endpoints map[string]idempotencyEntry
+	sync.Mutex
+}
+
+// idempotencyEntry holds cached endpoint idempotency
+type idempotencyEntry struct {
+	expires    time.Time
+	idempotent bool
+}
+
+func newIdempotency() *idempotency {
+	return &idempotency{endpoints: make(map[string]idempotencyEntry)}
+}
+
+// idempotent checks that all registered services mark endpoint as idempotent
+func (c *idempotency) idempotent(ctx context.Context, req Request, opts CallOptions) bool {
+	if opts.Router == nil || opts.Router.Options().Register == nil {
+		return false
+	}
+	reg := opts.Router.Options().Register
+	key := req.Service() + "." + req.Endpoint()
+
+	c.Lock()
+	if c.reg != reg {
+		c.reg = reg
+		c.endpoints = make(map[string]idempotencyEntry)
+	}
+	if e, ok := c.endpoints[key]; ok && time.Now().Before(e.expires) {
+		c.Unlock()
+		return e.idempotent
+	}
+	c.Unlock()
+
+	services, err := reg.LookupService(ctx, req.Service())
+	if err != nil {
+		return false
+	}
+	v := endpointIdempotent(services, req.Endpoint())
+
+	c.Lock()
+	if c.reg == reg {
+		// expired entries replaced, so cache grows only with called endpoints
+		c.endpoints[key] = idempotencyEntry{idempotent: v, expires: time.Now().Add(DefaultIdempotencyTTL)}
+	}
+	c.Unlock()
+
+	return v
+}
+
+// endpointIdempotent checks that all services mark endpoint as idempotent
+func endpointIdempotent(services []*register.Service, endpoint string) bool {
+	var found bool
+	for _, service := range services {
+		for _, ep := range service.Endpoints {
+			if ep.Name != endpoint {
+				continue
+			}
+			if v, ok := ep.Metadata.Get(register.MetadataIdempotent); !ok || v != "true" {
+				return false
+			}
+			found = true
+		}
+	}
+
+	return found
+}
+
+// hedge sends request to node and duplicates it to other node after each delay,
+// first successful response returned and other requests cancelled
+func (n *noopClient) hedge(ctx context.Context, req Request, rsp interface{}, opts CallOptions, hcall CallFunc, next selector.Next, nodes int, delay time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	max := opts.HedgeMax
+	if max < 1 {
+		max = 1
+	}
+
+	type result struct {
+		rsp interface{}
+		md  *metadata.Metadata
+		err error
+	}
+
+	ch := make(chan result, max+1)
+	used := make(map[string]bool, max+1)
+	typ := reflect.TypeOf(rsp).Elem()
+	key := req.Service() + "." + req.Endpoint()
+
+	send := func() bool {
+		node := next()
+		for i := 0; used[node] && i < 2*nodes; i++ {
+			node = next()
+		}
+		if used[node] {
+			return false
+		}
+		used[node] = true
+
+		r := result{rsp: reflect.New(typ).Interface()}
+		hopts := opts
+		if opts.ResponseMetadata != nil {
+			md := metadata.New(0)
+			r.md = &md
+			hopts.ResponseMetadata = r.md
+		}
+
+		go func() {
+			ts := time.Now()
+			r.err = hcall(ctx, node, req, r.rsp, hopts)
+			// cancelled requests must not affect future routing decisions
+			if r.err == nil || ctx.Err() == nil {
+				if verr := n.opts.Selector.Record(node, r.err); verr != nil && r.err == nil {
+					r.err = verr
+				}
+			}
+			if r.err == nil && opts.HedgePercentile > 0 {
+				n.latency.record(key, time.Since(ts))
+			}
+			ch <- r
+		}()
+
+		return true
+	}
+
+	send()
+	inflight, sent := 1, 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var gerr error
+	for inflight > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.New("go.micro.client", fmt.Sprintf("%v", ctx.Err()), 408)
+		case <-timer.C:
+			if sent <= max && send() {
+				sent++
+				inflight++
+				timer.Reset(delay)
+			}
+		case r := <-ch:
+			inflight--
+			if r.err == nil {
+				reflect.ValueOf(rsp).Elem().Set(reflect.ValueOf(r.rsp).Elem())
+				if r.md != nil {
+					*opts.ResponseMetadata = *r.md
+				}
+				return nil
+			}
+			gerr = r.err
+		}
+	}
+
+	return gerr
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/register"
+	"go.unistack.org/micro/v3/router"
+	"go.unistack.org/micro/v3/selector/roundrobin"
+)
+
+func newTestHedgeClient(t *testing.T) (Client, func() []string) {
+	reg := register.NewRegister()
+	err := reg.Register(context.TODO(), &register.Service{
+		Name:    "test",
+		Version: "latest",
+		Nodes:   []*register.Node{{ID: "test-1", Address: "127.0.0.1:1"}},
+		Endpoints: []*register.Endpoint{
+			{Name: "Test.Get", Metadata: metadata.Metadata{register.MetadataIdempotent: "true"}},
+			{Name: "Test.Create", Metadata: metadata.New(0)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	// first request is slow, next requests are fast
+	wrapper := func(CallFunc) CallFunc {
+		return func(ctx context.Context, addr string, req Request, rsp interface{}, opts CallOptions) error {
+			mu.Lock()
+			calls = append(calls, addr)
+			first := len(calls) == 1
+			mu.Unlock()
+			if first {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			*(rsp.(*string)) = addr
+			return nil
+		}
+	}
+
+	c := NewClient(
+		Router(router.NewRouter(router.Register(reg))),
+		Selector(roundrobin.NewSelector()),
+		WrapCall(wrapper),
+	)
+
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, calls...)
+	}
+}
+
+func TestHedgeIdempotent(t *testing.T) {
+	c, calls := newTestHedgeClient(t)
+
+	var rsp string
+	ts := time.Now()
+	err := c.Call(context.TODO(), &testRequest{service: "test", endpoint: "Test.Get"}, &rsp,
+		WithAddress("127.0.0.1:1", "127.0.0.1:2"), WithHedge(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(ts) >= 100*time.Millisecond {
+		t.Fatal("hedged request must return before slow request")
+	}
+
+	addrs := calls()
+	if len(addrs) != 2 || addrs[0] == addrs[1] {
+		t.Fatalf("hedged request must be sent to other node, calls %v", addrs)
+	}
+	if rsp != addrs[1] {
+		t.Fatalf("response must be from hedged request, got %s", rsp)
+	}
+}
+
+func TestHedgeNotIdempotent(t *testing.T) {
+	c, calls := newTestHedgeClient(t)
+
+	var rsp string
+	err := c.Call(context.TODO(), &testRequest{service: "test", endpoint: "Test.Create"}, &rsp,
+		WithAddress("127.0.0.1:1", "127.0.0.1:2"), WithHedge(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs := calls(); len(addrs) != 1 || rsp != addrs[0] {
+		t.Fatalf("not idempotent endpoint must not be hedged, calls %v", addrs)
+	}
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	l := newLatencies()
+	if _, ok := l.percentile("test", 0.9); ok {
+		t.Fatal("percentile must not be available without latencies")
+	}
+	for i := 1; i <= DefaultHedgeSamples+10; i++ {
+		l.record("test", time.Duration(i)*time.Millisecond)
+	}
+	// oldest latencies replaced, window holds 11..110ms
+	if d, ok := l.percentile("test", 0.9); !ok || d != 101*time.Millisecond {
+		t.Fatalf("invalid percentile %s", d)
+	}
+}
+
+// registerAlias embedded to avoid field and Register method name clash
+type registerAlias = register.Register
+
+type lookupCountRegister struct {
+	registerAlias
+	lookups int
+	sync.Mutex
+}
+
+func (r *lookupCountRegister) LookupService(ctx context.Context, name string, opts ...register.LookupOption) ([]*register.Service, error) {
+	r.Lock()
+	r.lookups++
+	r.Unlock()
+	return r.registerAlias.LookupService(ctx, name, opts...)
+}
+
+func (r *lookupCountRegister) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.lookups
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	reg := &lookupCountRegister{registerAlias: register.NewRegister()}
+	service := &register.Service{
+		Name:      "test",
+		Version:   "latest",
+		Nodes:     []*register.Node{{ID: "test-1", Address: "127.0.0.1:1"}},
+		Endpoints: []*register.Endpoint{{Name: "Test.Get", Metadata: metadata.Metadata{register.MetadataIdempotent: "true"}}},
+	}
+	if err := reg.Register(context.TODO(), service); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newIdempotency()
+	opts := NewCallOptions(WithRouter(router.NewRouter(router.Register(reg))))
+	req := &testRequest{service: "test", endpoint: "Test.Get"}
+
+	for i := 0; i < 3; i++ {
+		if !c.idempotent(context.TODO(), req, opts) {
+			t.Fatal("endpoint must be idempotent")
+		}
+	}
+	if n := reg.count(); n != 1 {
+		t.Fatalf("idempotency must be looked up once, got %d lookups", n)
+	}
+
+	service.Endpoints[0].Metadata = metadata.New(0)
+	if err := reg.Register(context.TODO(), service); err != nil {
+		t.Fatal(err)
+	}
+	// cached endpoint looked up again after ttl
+	c.Lock()
+	c.endpoints["test.Test.Get"] = idempotencyEntry{idempotent: true, expires: time.Now()}
+	c.Unlock()
+	if c.idempotent(context.TODO(), req, opts) {
+		t.Fatal("changed service endpoint must not be idempotent")
+	}
+	if n := reg.count(); n != 2 {
+		t.Fatalf("expired idempotency must be looked up again, got %d lookups", n)
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"sort"
 
 	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/register"
 	"go.unistack.org/micro/v3/router"
+	"go.unistack.org/micro/v3/util/rand"
 )
 
 // LookupFunc is used to lookup routes for a service
 type LookupFunc func(context.Context, Request, CallOptions) ([]string, error)
 
 // LookupRoute for a request using the router and then choose one using the selector
-func LookupRoute(_ context.Context, req Request, opts CallOptions) ([]string, error) {
+func LookupRoute(ctx context.Context, req Request, opts CallOptions) ([]string, error) {
 	// check to see if an address was provided as a call option
 	if len(opts.Address) > 0 {
 		return opts.Address, nil
@@ -39,6 +42,12 @@ func LookupRoute(_ context.Context, req Request, opts CallOptions) ([]string, er
 		return nil, errors.InternalServerError("go.micro.client", "error getting next %s node: %s", req.Service(), err.Error())
 	}
 
+	// filter routes by service version
+	routes, err = lookupVersion(ctx, routes, opts)
+	if err != nil {
+		return nil, errors.InternalServerError("go.micro.client", "service %s: %s", req.Service(), err.Error())
+	}
+
 	// sort by lowest metric first
 	sort.Slice(routes, func(i, j int) bool {
 		return routes[i].Metric < routes[j].Metric
@@ -51,3 +60,92 @@ func LookupRoute(_ context.Context, req Request, opts CallOptions) ([]string, er
 
 	return addrs, nil
 }
+
+// lookupVersion returns routes that matches version requested by metadata header,
+// pinned version, version weights or preferred version in that order
+func lookupVersion(ctx context.Context, routes []router.Route, opts CallOptions) ([]router.Route, error) {
+	header := opts.VersionHeader
+	if header == "" {
+		header = metadata.HeaderVersion
+	}
+
+	version := opts.Version
+	if v, ok := opts.RequestMetadata.Get(header); ok && v != "" {
+		version = v
+	} else if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if v, ok = md.Get(header); ok && v != "" {
+			version = v
+		}
+	}
+
+	// strict version
+	if version != "" {
+		if filtered := filterVersion(routes, version); len(filtered) > 0 {
+			return filtered, nil
+		}
+		return nil, router.ErrRouteNotFound
+	}
+
+	// weighted traffic split between available versions
+	if len(opts.VersionWeights) > 0 {
+		if v := weightedVersion(routes, opts.VersionWeights); v != "" {
+			return filterVersion(routes, v), nil
+		}
+	}
+
+	// preferred version if it available
+	if opts.PreferVersion != "" {
+		if filtered := filterVersion(routes, opts.PreferVersion); len(filtered) > 0 {
+			return filtered, nil
+		}
+	}
+
+	return routes, nil
+}
+
+// filterVersion returns routes with specified version
+func filterVersion(routes []router.Route, version string) []router.Route {
+	filtered := make([]router.Route, 0, len(routes))
+	for _, route := range routes {
+		if v, ok := route.Metadata.Get(register.MetadataVersion); ok && v == version {
+			filtered = append(filtered, route)
+		}
+	}
+	return filtered
+}
+
+// weightedVersion picks random version from versions that exists in routes
+// according to its weights, if no weighted versions available empty string returned
+func weightedVersion(routes []router.Route, weights map[string]int) string {
+	available := make(map[string]struct{}, len(weights))
+	for _, route := range routes {
+		if v, ok := route.Metadata.Get(register.MetadataVersion); ok {
+			if w := weights[v]; w > 0 {
+				available[v] = struct{}{}
+			}
+		}
+	}
+
+	// sort versions to get stable results
+	versions := make([]string, 0, len(available))
+	var total int
+	for v := range available {
+		versions = append(versions, v)
+		total += weights[v]
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.Strings(versions)
+
+	var rng rand.Rand
+	n := rng.Intn(total)
+	for _, v := range versions {
+		if n < weights[v] {
+			return v
+		}
+		n -= weights[v]
+	}
+
+	return ""
+}
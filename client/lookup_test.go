@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/register"
+	"go.unistack.org/micro/v3/router"
+)
+
+type testRouter struct {
+	router.Router
+	routes []router.Route
+}
+
+func (r *testRouter) Lookup(...router.QueryOption) ([]router.Route, error) {
+	return r.routes, nil
+}
+
+func newTestRouter() router.Router {
+	return &testRouter{routes: []router.Route{
+		{Service: "test", Address: "127.0.0.1:1", Metadata: metadata.Metadata{register.MetadataVersion: "v1"}},
+		{Service: "test", Address: "127.0.0.1:2", Metadata: metadata.Metadata{register.MetadataVersion: "v1"}},
+		{Service: "test", Address: "127.0.0.1:3", Metadata: metadata.Metadata{register.MetadataVersion: "v2"}},
+	}}
+}
+
+func TestLookupRouteVersion(t *testing.T) {
+	req := &testRequest{service: "test"}
+	opts := NewCallOptions(WithRouter(newTestRouter()), WithVersion("v2"))
+
+	addrs, err := LookupRoute(context.TODO(), req, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "127.0.0.1:3" {
+		t.Fatalf("invalid addrs %v", addrs)
+	}
+
+	opts = NewCallOptions(WithRouter(newTestRouter()), WithVersion("v3"))
+	if _, err = LookupRoute(context.TODO(), req, opts); err == nil {
+		t.Fatal("lookup of missing version must fail")
+	}
+
+	opts = NewCallOptions(WithRouter(newTestRouter()), WithPreferVersion("v3"))
+	if addrs, err = LookupRoute(context.TODO(), req, opts); err != nil {
+		t.Fatal(err)
+	} else if len(addrs) != 3 {
+		t.Fatalf("invalid addrs %v", addrs)
+	}
+}
+
+func TestLookupRouteVersionHeader(t *testing.T) {
+	req := &testRequest{service: "test"}
+	opts := NewCallOptions(WithRouter(newTestRouter()), WithVersion("v2"))
+	ctx := metadata.AppendOutgoingContext(context.TODO(), metadata.HeaderVersion, "v1")
+
+	addrs, err := LookupRoute(ctx, req, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("invalid addrs %v", addrs)
+	}
+}
+
+func TestLookupRouteVersionWeights(t *testing.T) {
+	req := &testRequest{service: "test"}
+	opts := NewCallOptions(WithRouter(newTestRouter()), WithVersionWeights(map[string]int{"v1": 95, "v2": 5}))
+
+	counts := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		addrs, err := LookupRoute(context.TODO(), req, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[len(addrs)]++
+	}
+	if counts[2] < counts[1] || counts[1] == 0 {
+		t.Fatalf("invalid traffic split %v", counts)
+	}
+
+	opts = NewCallOptions(WithRouter(newTestRouter()), WithVersionWeights(map[string]int{"v2": 100}))
+	addrs, err := LookupRoute(context.TODO(), req, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 {
+		t.Fatalf("invalid addrs %v", addrs)
+	}
+}
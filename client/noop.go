@@ -18,7 +18,9 @@ var DefaultCodecs = map[string]codec.Codec{
 }
 
 type noopClient struct {
-	opts Options
+	latency     *latencies
+	idempotency *idempotency
+	opts        Options
 }
 
 type noopMessage struct {
@@ -39,7 +41,7 @@ type noopRequest struct {
 
 // NewClient returns new noop client
 func NewClient(opts ...Option) Client {
-	nc := &noopClient{opts: NewOptions(opts...)}
+	nc := &noopClient{opts: NewOptions(opts...), latency: newLatencies(), idempotency: newIdempotency()}
 	// wrap in reverse
 
 	c := Client(nc)
@@ -235,18 +237,23 @@ func (n *noopClient) Call(ctx context.Context, req Request, rsp interface{}, opt
 	}
 
 	var next selector.Next
+	var nodes int
 
 	// return errors.New("go.micro.client", "request timeout", 408)
-	call := func(i int) error {
+	call := func(i int, backoff bool) error {
+		var err error
 		// call backoff first. Someone may want an initial start delay
-		t, err := callOpts.Backoff(ctx, req, i)
-		if err != nil {
-			return errors.InternalServerError("go.micro.client", err.Error())
-		}
+		if backoff {
+			var t time.Duration
+			t, err = callOpts.Backoff(ctx, req, i)
+			if err != nil {
+				return errors.InternalServerError("go.micro.client", err.Error())
+			}
 
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
-			time.Sleep(t)
+			// only sleep if greater than 0
+			if t.Seconds() > 0 {
+				time.Sleep(t)
+			}
 		}
 
 		if next == nil {
@@ -263,15 +270,28 @@ func (n *noopClient) Call(ctx context.Context, req Request, rsp interface{}, opt
 			if err != nil {
 				return err
 			}
+			nodes = len(routes)
 		}
 
-		node := next()
+		// pass remaining deadline to the server
+		ctx := deadlineContext(ctx)
 
-		// make the call
-		err = hcall(ctx, node, req, rsp, callOpts)
-		// record the result of the call to inform future routing decisions
-		if verr := n.opts.Selector.Record(node, err); verr != nil {
-			return verr
+		// duplicate request to other nodes if response is late
+		if delay := n.hedgeDelay(ctx, req, rsp, callOpts); delay > 0 && nodes > 1 {
+			err = n.hedge(ctx, req, rsp, callOpts, hcall, next, nodes, delay)
+		} else {
+			node := next()
+
+			// make the call
+			ts := time.Now()
+			err = hcall(ctx, node, req, rsp, callOpts)
+			// record the result of the call to inform future routing decisions
+			if verr := n.opts.Selector.Record(node, err); verr != nil {
+				return verr
+			}
+			if err == nil && callOpts.HedgePercentile > 0 {
+				n.latency.record(req.Service()+"."+req.Endpoint(), time.Since(ts))
+			}
 		}
 
 		// try and transform the error to a go-micro error
@@ -285,10 +305,16 @@ func (n *noopClient) Call(ctx context.Context, req Request, rsp interface{}, opt
 	ch := make(chan error, callOpts.Retries)
 	var gerr error
 
+	if callOpts.RetryBudget != nil {
+		callOpts.RetryBudget.Request(req)
+	}
+
+	// backoff skipped after server requested delay already waited
+	backoff := true
 	for i := 0; i <= callOpts.Retries; i++ {
-		go func() {
-			ch <- call(i)
-		}()
+		go func(i int, backoff bool) {
+			ch <- call(i, backoff)
+		}(i, backoff)
 
 		select {
 		case <-ctx.Done():
@@ -308,6 +334,25 @@ func (n *noopClient) Call(ctx context.Context, req Request, rsp interface{}, opt
 				return err
 			}
 
+			// retry budget exhausted, retries must not amplify load
+			if i < callOpts.Retries && callOpts.RetryBudget != nil && !callOpts.RetryBudget.Retry(req) {
+				return err
+			}
+
+			// wait the delay requested by server
+			backoff = true
+			if d, ok := errors.RetryAfter(err); ok && d > 0 && i < callOpts.Retries {
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return errors.New("go.micro.client", fmt.Sprintf("%v", ctx.Err()), 408)
+				case <-time.After(d):
+				}
+				backoff = false
+			}
+
 			gerr = err
 		}
 	}
@@ -414,7 +459,8 @@ func (n *noopClient) Stream(ctx context.Context, req Request, opts ...CallOption
 
 		node := next()
 
-		stream, cerr := n.stream(ctx, node, req, callOpts)
+		// pass remaining deadline to the server
+		stream, cerr := n.stream(deadlineContext(ctx), node, req, callOpts)
 
 		// record the result of the call to inform future routing decisions
 		if verr := n.opts.Selector.Record(node, cerr); verr != nil {
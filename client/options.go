@@ -80,6 +80,8 @@ type CallOptions struct {
 	Router router.Router
 	// Retry func used for retries
 	Retry RetryFunc
+	// RetryBudget limits retries, checked after Retry func allowed retry
+	RetryBudget RetryBudget
 	// Backoff func used for backoff when retry
 	Backoff BackoffFunc
 	// Network name
@@ -108,6 +110,20 @@ type CallOptions struct {
 	Retries int
 	// ContextDialer used to connect
 	ContextDialer func(context.Context, string) (net.Conn, error)
+	// VersionWeights holds weights used to split traffic between service versions
+	VersionWeights map[string]int
+	// Version specifies service version that must handle the call
+	Version string
+	// PreferVersion specifies service version that used if it available
+	PreferVersion string
+	// VersionHeader specifies metadata key that forces service version
+	VersionHeader string
+	// HedgeDelay specifies delay before hedged request sent
+	HedgeDelay time.Duration
+	// HedgePercentile specifies endpoint latency percentile used as hedge delay
+	HedgePercentile float64
+	// HedgeMax specifies max number of hedged requests
+	HedgeMax int
 }
 
 // ContextDialer pass ContextDialer to client
@@ -371,6 +387,13 @@ func Retry(fn RetryFunc) Option {
 	}
 }
 
+// SharedRetryBudget sets the retry budget shared by all calls of the client
+func SharedRetryBudget(b RetryBudget) Option {
+	return func(o *Options) {
+		o.CallOptions.RetryBudget = b
+	}
+}
+
 // RequestTimeout is the request timeout.
 func RequestTimeout(d time.Duration) Option {
 	return func(o *Options) {
@@ -473,6 +496,14 @@ func WithRetry(fn RetryFunc) CallOption {
 	}
 }
 
+// WithRetryBudget sets the retry budget checked after the retry func allowed retry,
+// call not retried if budget exhausted
+func WithRetryBudget(b RetryBudget) CallOption {
+	return func(o *CallOptions) {
+		o.RetryBudget = b
+	}
+}
+
 // WithRetries is a CallOption which overrides that which
 // set in Options.CallOptions
 func WithRetries(i int) CallOption {
@@ -554,6 +585,61 @@ func WithSelectOptions(sops ...selector.SelectOption) CallOption {
 	}
 }
 
+// WithVersion pins the call to the given service version
+func WithVersion(v string) CallOption {
+	return func(o *CallOptions) {
+		o.Version = v
+	}
+}
+
+// WithPreferVersion prefers the given service version if it available,
+// otherwise any version used
+func WithPreferVersion(v string) CallOption {
+	return func(o *CallOptions) {
+		o.PreferVersion = v
+	}
+}
+
+// WithVersionWeights splits calls between service versions by weights,
+// for example {"v1": 95, "v2": 5} sends 5% of calls to canary version v2
+func WithVersionWeights(weights map[string]int) CallOption {
+	return func(o *CallOptions) {
+		o.VersionWeights = weights
+	}
+}
+
+// WithVersionHeader sets the metadata key that forces service version,
+// by default metadata.HeaderVersion used
+func WithVersionHeader(key string) CallOption {
+	return func(o *CallOptions) {
+		o.VersionHeader = key
+	}
+}
+
+// WithHedge sends duplicate request to other node if no response received after delay,
+// first successful response returned and other requests cancelled.
+// Hedged requests sent only to endpoints marked by server.EndpointIdempotent
+func WithHedge(delay time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.HedgeDelay = delay
+	}
+}
+
+// WithHedgePercentile sets hedge delay to the endpoint latency percentile like 0.95,
+// delay passed to WithHedge used until enough latencies recorded
+func WithHedgePercentile(p float64) CallOption {
+	return func(o *CallOptions) {
+		o.HedgePercentile = p
+	}
+}
+
+// WithHedgeMax sets max number of hedged requests sent in addition to the first one, by default 1
+func WithHedgeMax(n int) CallOption {
+	return func(o *CallOptions) {
+		o.HedgeMax = n
+	}
+}
+
 // WithMessageContentType sets the message content type
 // Deprecated
 func WithMessageContentType(ct string) MessageOption {
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/imdario/mergo"
+	rutil "go.unistack.org/micro/v3/util/reflect"
+)
+
+type envPrefixKey struct{}
+
+// EnvPrefix sets the prefix prepended to all environment variable names
+func EnvPrefix(prefix string) Option {
+	return SetOption(envPrefixKey{}, prefix)
+}
+
+type envConfig struct {
+	opts Options
+}
+
+// NewEnvConfig returns new config source that fills struct from environment variables.
+// Variable names are taken from struct tag, multiple names separated by comma,
+// first existing variable used. Tag on nested struct field used as prefix for its fields,
+// for example `env:"DB"` on struct field and `env:"HOST"` inside it reads DB_HOST.
+func NewEnvConfig(opts ...Option) Config {
+	options := NewOptions(opts...)
+	if len(options.StructTag) == 0 {
+		options.StructTag = "env"
+	}
+	return &envConfig{opts: options}
+}
+
+func (c *envConfig) Options() Options {
+	return c.opts
+}
+
+func (c *envConfig) Init(opts ...Option) error {
+	for _, o := range opts {
+		o(&c.opts)
+	}
+	return nil
+}
+
+func (c *envConfig) Load(ctx context.Context, opts ...LoadOption) error {
+	if err := DefaultBeforeLoad(ctx, c); err != nil {
+		return err
+	}
+
+	options := NewLoadOptions(opts...)
+	mopts := []func(*mergo.Config){mergo.WithTypeCheck}
+	if options.Override {
+		mopts = append(mopts, mergo.WithOverride)
+	}
+	if options.Append {
+		mopts = append(mopts, mergo.WithAppendSlice)
+	}
+
+	dst := c.opts.Struct
+	if options.Struct != nil {
+		dst = options.Struct
+	}
+
+	src, err := rutil.Zero(dst)
+	if err != nil {
+		if !c.opts.AllowFail {
+			return err
+		}
+		return DefaultAfterLoad(ctx, c)
+	}
+
+	prefix, _ := c.opts.Context.Value(envPrefixKey{}).(string)
+
+	if err = fillEnvValues(reflect.ValueOf(src), c.opts.StructTag, prefix); err == nil {
+		err = mergo.Merge(dst, src, mopts...)
+	}
+
+	if err != nil {
+		c.opts.Logger.Errorf(ctx, "env load error: %v", err)
+		if !c.opts.AllowFail {
+			return err
+		}
+	}
+
+	if err := DefaultAfterLoad(ctx, c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func fillEnvValues(valueOf reflect.Value, tname string, prefix string) error {
+	var values reflect.Value
+
+	if valueOf.Kind() == reflect.Ptr {
+		values = valueOf.Elem()
+	} else {
+		values = valueOf
+	}
+
+	if values.Kind() == reflect.Invalid {
+		return ErrInvalidStruct
+	}
+
+	fields := values.Type()
+
+	for idx := 0; idx < fields.NumField(); idx++ {
+		field := fields.Field(idx)
+		value := values.Field(idx)
+		if !value.CanSet() {
+			continue
+		}
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup(tname)
+		if tag == "-" {
+			continue
+		}
+
+		switch value.Kind() {
+		case reflect.Struct, reflect.Ptr:
+			if value.Kind() == reflect.Ptr && value.Type().Elem().Kind() != reflect.Struct {
+				break
+			}
+			nprefix := prefix
+			if ok && len(tag) > 0 {
+				nprefix = prefix + tag + "_"
+			}
+			nvalue := reflect.New(value.Type())
+			if value.Kind() == reflect.Ptr {
+				nvalue = reflect.New(value.Type().Elem())
+			}
+			if err := fillEnvValues(nvalue, tname, nprefix); err != nil {
+				return err
+			}
+			// set nested struct only if any value found to avoid allocation of empty pointers
+			if rutil.IsEmpty(nvalue) {
+				continue
+			}
+			if value.Kind() == reflect.Ptr {
+				value.Set(nvalue)
+			} else {
+				value.Set(nvalue.Elem())
+			}
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		for _, name := range strings.Split(tag, ",") {
+			val, found := os.LookupEnv(prefix + strings.TrimSpace(name))
+			if !found {
+				continue
+			}
+			if err := fillValue(value, val); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+func (c *envConfig) Save(ctx context.Context, opts ...SaveOption) error {
+	if err := DefaultBeforeSave(ctx, c); err != nil {
+		return err
+	}
+
+	if err := DefaultAfterSave(ctx, c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *envConfig) String() string {
+	return "env"
+}
+
+func (c *envConfig) Name() string {
+	return c.opts.Name
+}
+
+func (c *envConfig) Watch(ctx context.Context, opts ...WatchOption) (Watcher, error) {
+	return nil, ErrWatcherNotImplemented
+}
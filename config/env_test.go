@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/config"
+)
+
+type envCfg struct {
+	StringValue   string            `env:"STRING_VALUE,STRING"`
+	IntValue      int               `env:"INT_VALUE"`
+	DurationValue time.Duration     `env:"DURATION_VALUE"`
+	SliceValue    []string          `env:"SLICE_VALUE"`
+	MapValue      map[string]int    `env:"MAP_VALUE"`
+	Nested        *envCfgNested     `env:"NESTED"`
+	IgnoreValue   string            `env:"-"`
+	Labels        map[string]string `env:"LABELS"`
+}
+
+type envCfgNested struct {
+	BoolValue bool `env:"BOOL_VALUE"`
+}
+
+func TestEnv(t *testing.T) {
+	ctx := context.Background()
+	for k, v := range map[string]string{
+		"APP_STRING":              "string_value",
+		"APP_INT_VALUE":           "99",
+		"APP_DURATION_VALUE":      "10s",
+		"APP_SLICE_VALUE":         "a,b,c",
+		"APP_MAP_VALUE":           "a=1;b=2",
+		"APP_NESTED_BOOL_VALUE":   "true",
+		"APP_IGNORE_VALUE":        "ignore",
+		"APP_UNUSED_STRING_VALUE": "unused",
+	} {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Unsetenv(k)
+	}
+
+	conf := &envCfg{}
+	cfg := config.NewEnvConfig(config.Struct(conf), config.EnvPrefix("APP_"))
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.StringValue != "string_value" || conf.IntValue != 99 || conf.DurationValue != 10*time.Second {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+	if len(conf.SliceValue) != 3 || conf.MapValue["b"] != 2 {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+	if conf.Nested == nil || !conf.Nested.BoolValue {
+		t.Fatalf("invalid nested config %#+v", conf.Nested)
+	}
+	if conf.IgnoreValue != "" || conf.Labels != nil {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+}
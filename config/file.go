@@ -0,0 +1,377 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/imdario/mergo"
+	"go.unistack.org/micro/v3/util/rand"
+	rutil "go.unistack.org/micro/v3/util/reflect"
+)
+
+// ErrPathMissing is returned when file config used without path
+var ErrPathMissing = errors.New("path missing")
+
+type filePathKey struct{}
+
+// FilePath sets the path of config file
+func FilePath(path string) Option {
+	return SetOption(filePathKey{}, path)
+}
+
+type fileConfig struct {
+	opts Options
+	path string
+}
+
+// NewFileConfig returns new config source that loads struct from file.
+// File content decoded by the codec passed via Codec option, so any format
+// supported by codec (json, yaml, toml) can be used.
+func NewFileConfig(opts ...Option) Config {
+	c := &fileConfig{opts: NewOptions(opts...)}
+	c.path, _ = c.opts.Context.Value(filePathKey{}).(string)
+	return c
+}
+
+func (c *fileConfig) Options() Options {
+	return c.opts
+}
+
+func (c *fileConfig) Init(opts ...Option) error {
+	for _, o := range opts {
+		o(&c.opts)
+	}
+	if path, ok := c.opts.Context.Value(filePathKey{}).(string); ok {
+		c.path = path
+	}
+	if len(c.path) == 0 {
+		return ErrPathMissing
+	}
+	if c.opts.Codec == nil {
+		return ErrCodecMissing
+	}
+	return nil
+}
+
+func (c *fileConfig) Load(ctx context.Context, opts ...LoadOption) error {
+	if err := DefaultBeforeLoad(ctx, c); err != nil {
+		return err
+	}
+
+	options := NewLoadOptions(opts...)
+	mopts := []func(*mergo.Config){mergo.WithTypeCheck}
+	if options.Override {
+		mopts = append(mopts, mergo.WithOverride)
+	}
+	if options.Append {
+		mopts = append(mopts, mergo.WithAppendSlice)
+	}
+
+	dst := c.opts.Struct
+	if options.Struct != nil {
+		dst = options.Struct
+	}
+
+	src, err := c.read(dst)
+	if err == nil {
+		err = mergo.Merge(dst, src, mopts...)
+	}
+
+	if err != nil {
+		c.opts.Logger.Errorf(ctx, "file load error: %v", err)
+		if !c.opts.AllowFail {
+			return err
+		}
+	}
+
+	if err := DefaultAfterLoad(ctx, c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// read returns new struct with the same type as dst filled from file
+func (c *fileConfig) read(dst interface{}) (interface{}, error) {
+	if c.opts.Codec == nil {
+		return nil, ErrCodecMissing
+	}
+
+	src, err := rutil.Zero(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buf) == 0 {
+		return src, nil
+	}
+
+	if err = c.opts.Codec.Unmarshal(buf, src); err != nil {
+		return nil, err
+	}
+
+	return src, nil
+}
+
+func (c *fileConfig) Save(ctx context.Context, opts ...SaveOption) error {
+	if err := DefaultBeforeSave(ctx, c); err != nil {
+		return err
+	}
+
+	options := NewSaveOptions(opts...)
+
+	src := c.opts.Struct
+	if options.Struct != nil {
+		src = options.Struct
+	}
+
+	err := c.write(src)
+	if err != nil {
+		c.opts.Logger.Errorf(ctx, "file save error: %v", err)
+		if !c.opts.AllowFail {
+			return err
+		}
+	}
+
+	if err := DefaultAfterSave(ctx, c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// write atomically replaces file content with encoded src
+func (c *fileConfig) write(src interface{}) error {
+	if c.opts.Codec == nil {
+		return ErrCodecMissing
+	}
+
+	buf, err := c.opts.Codec.Marshal(src)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(c.path); err == nil {
+		mode = fi.Mode()
+	}
+
+	fp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+
+	if _, err = fp.Write(buf); err != nil {
+		_ = fp.Close()
+		return err
+	}
+	if err = fp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(fp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(fp.Name(), c.path)
+}
+
+func (c *fileConfig) String() string {
+	return "file"
+}
+
+func (c *fileConfig) Name() string {
+	return c.opts.Name
+}
+
+func (c *fileConfig) Watch(ctx context.Context, opts ...WatchOption) (Watcher, error) {
+	options := NewWatchOptions(opts...)
+
+	dst := c.opts.Struct
+	if options.Struct != nil {
+		dst = options.Struct
+	}
+
+	w := &fileWatcher{
+		c:      c,
+		opts:   options,
+		dst:    dst,
+		vchan:  make(chan map[string]interface{}),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	// remember current state of file to report only changed fields
+	if fi, err := os.Stat(c.path); err == nil {
+		w.modTime = fi.ModTime()
+		w.size = fi.Size()
+	}
+	src, err := c.read(dst)
+	if err != nil {
+		if src, err = rutil.Zero(dst); err != nil {
+			return nil, err
+		}
+	}
+	if w.fields, err = rutil.StructFieldsMap(src); err != nil {
+		return nil, err
+	}
+
+	w.min, w.max = options.MinInterval, options.MaxInterval
+	if w.max <= w.min {
+		w.max = w.min + time.Millisecond
+	}
+
+	go w.run(ctx)
+
+	return w, nil
+}
+
+type fileWatcher struct {
+	modTime time.Time
+	dst     interface{}
+	c       *fileConfig
+	fields  map[string]interface{}
+	pending map[string]interface{}
+	vchan   chan map[string]interface{}
+	notify  chan struct{}
+	done    chan struct{}
+	opts    WatchOptions
+	rng     rand.Rand
+	min     time.Duration
+	max     time.Duration
+	size    int64
+	sync.Mutex
+}
+
+// run polls file at random intervals between min and max, timer reset only after changes
+// delivered, so slow consumer delays polling instead of losing it
+func (w *fileWatcher) run(ctx context.Context) {
+	timer := time.NewTimer(w.interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			_ = w.Stop()
+			return
+		case <-w.done:
+			return
+		case <-timer.C:
+		}
+
+		changes, err := w.check()
+		if err != nil {
+			w.c.opts.Logger.Errorf(ctx, "file watch error: %v", err)
+		} else if len(changes) > 0 && w.opts.Coalesce {
+			w.Lock()
+			if w.pending == nil {
+				w.pending = changes
+			} else {
+				for k, v := range changes {
+					w.pending[k] = v
+				}
+			}
+			w.Unlock()
+			select {
+			case w.notify <- struct{}{}:
+			default:
+			}
+		} else if len(changes) > 0 {
+			select {
+			case w.vchan <- changes:
+			case <-w.done:
+				return
+			case <-ctx.Done():
+				_ = w.Stop()
+				return
+			}
+		}
+
+		timer.Reset(w.interval())
+	}
+}
+
+// interval returns random poll interval between min and max
+func (w *fileWatcher) interval() time.Duration {
+	return time.Duration(w.rng.Int63n(int64(w.max-w.min))) + w.min
+}
+
+// check returns changed fields if file modified since last check
+func (w *fileWatcher) check() (map[string]interface{}, error) {
+	fi, err := os.Stat(w.c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return nil, nil
+	}
+
+	src, err := w.c.read(w.dst)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := rutil.StructFieldsMap(src)
+	if err != nil {
+		return nil, err
+	}
+
+	w.modTime = fi.ModTime()
+	w.size = fi.Size()
+
+	changes := make(map[string]interface{})
+	for k, v := range fields {
+		if ov, ok := w.fields[k]; !ok || !reflect.DeepEqual(ov, v) {
+			changes[k] = v
+		}
+	}
+	w.fields = fields
+
+	return changes, nil
+}
+
+func (w *fileWatcher) Next() (map[string]interface{}, error) {
+	if w.opts.Coalesce {
+		select {
+		case <-w.done:
+			return nil, ErrWatcherStopped
+		case <-w.notify:
+			w.Lock()
+			changes := w.pending
+			w.pending = nil
+			w.Unlock()
+			return changes, nil
+		}
+	}
+
+	select {
+	case <-w.done:
+		return nil, ErrWatcherStopped
+	case changes := <-w.vchan:
+		return changes, nil
+	}
+}
+
+func (w *fileWatcher) Stop() error {
+	w.Lock()
+	defer w.Unlock()
+
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+
+	return nil
+}
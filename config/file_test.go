@@ -0,0 +1,112 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/codec"
+	"go.unistack.org/micro/v3/config"
+)
+
+type fileCfg struct {
+	StringValue string
+	IntValue    int
+	Nested      *fileCfgNested
+}
+
+type fileCfgNested struct {
+	BoolValue bool
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"StringValue":"string_value","IntValue":10}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &fileCfg{}
+	cfg := config.NewFileConfig(config.Struct(conf), config.Codec(codec.NewCodec()), config.FilePath(path))
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if conf.StringValue != "string_value" || conf.IntValue != 10 {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+
+	w, err := cfg.Watch(ctx, config.WatchInterval(10*time.Millisecond, 20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	conf.IntValue = 20
+	conf.Nested = &fileCfgNested{BoolValue: true}
+	if err = cfg.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes["IntValue"] != 20 || changes["Nested.BoolValue"] != true {
+		t.Fatalf("invalid changes %#+v", changes)
+	}
+}
+
+func TestFileWatchSlowConsumer(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"IntValue":10}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &fileCfg{}
+	cfg := config.NewFileConfig(config.Struct(conf), config.Codec(codec.NewCodec()), config.FilePath(path))
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cfg.Watch(ctx, config.WatchInterval(5*time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	next := func() map[string]interface{} {
+		ch := make(chan map[string]interface{}, 1)
+		go func() {
+			changes, _ := w.Next()
+			ch <- changes
+		}()
+		select {
+		case changes := <-ch:
+			return changes
+		case <-time.After(time.Second):
+			t.Fatal("watcher stalled")
+		}
+		return nil
+	}
+
+	for _, v := range []int{20, 300} {
+		conf.IntValue = v
+		if err = cfg.Save(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// consumer is slow, so watcher polls file and waits for consumer
+		time.Sleep(100 * time.Millisecond)
+		if changes := next(); changes["IntValue"] != v {
+			t.Fatalf("invalid changes %#+v", changes)
+		}
+	}
+}
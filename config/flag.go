@@ -0,0 +1,247 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/imdario/mergo"
+	rutil "go.unistack.org/micro/v3/util/reflect"
+)
+
+type (
+	flagSetKey  struct{}
+	flagArgsKey struct{}
+)
+
+// FlagSet sets the flag.FlagSet used to define and parse flags,
+// by default new flag set with flag.ContinueOnError created on each load
+func FlagSet(fs *flag.FlagSet) Option {
+	return SetOption(flagSetKey{}, fs)
+}
+
+// FlagArgs sets the command-line arguments to parse, by default os.Args[1:] used
+func FlagArgs(args []string) Option {
+	return SetOption(flagArgsKey{}, args)
+}
+
+type flagConfig struct {
+	opts Options
+}
+
+// NewFlagConfig returns new config source that fills struct from command-line flags.
+// Flag names are taken from struct tag, tag on nested struct field used as prefix
+// for its fields separated by dot. Help text is generated from the desc tag,
+// default value shown in help is taken from the default tag.
+func NewFlagConfig(opts ...Option) Config {
+	options := NewOptions(opts...)
+	if len(options.StructTag) == 0 {
+		options.StructTag = "flag"
+	}
+	return &flagConfig{opts: options}
+}
+
+func (c *flagConfig) Options() Options {
+	return c.opts
+}
+
+func (c *flagConfig) Init(opts ...Option) error {
+	for _, o := range opts {
+		o(&c.opts)
+	}
+	return nil
+}
+
+func (c *flagConfig) Load(ctx context.Context, opts ...LoadOption) error {
+	if err := DefaultBeforeLoad(ctx, c); err != nil {
+		return err
+	}
+
+	options := NewLoadOptions(opts...)
+	mopts := []func(*mergo.Config){mergo.WithTypeCheck}
+	if options.Override {
+		mopts = append(mopts, mergo.WithOverride)
+	}
+	if options.Append {
+		mopts = append(mopts, mergo.WithAppendSlice)
+	}
+
+	dst := c.opts.Struct
+	if options.Struct != nil {
+		dst = options.Struct
+	}
+
+	src, err := rutil.Zero(dst)
+	if err != nil {
+		if !c.opts.AllowFail {
+			return err
+		}
+		return DefaultAfterLoad(ctx, c)
+	}
+
+	fs, ok := c.opts.Context.Value(flagSetKey{}).(*flag.FlagSet)
+	if !ok || fs == nil {
+		fs = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	}
+	args, ok := c.opts.Context.Value(flagArgsKey{}).([]string)
+	if !ok {
+		args = os.Args[1:]
+	}
+
+	if err = defineFlags(fs, reflect.ValueOf(src), c.opts.StructTag); err == nil {
+		if err = fs.Parse(args); err == nil {
+			err = mergo.Merge(dst, src, mopts...)
+		}
+	}
+
+	if err != nil {
+		c.opts.Logger.Errorf(ctx, "flag load error: %v", err)
+		if !c.opts.AllowFail {
+			return err
+		}
+	}
+
+	if err := DefaultAfterLoad(ctx, c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// flagValue implements flag.Value and fills struct field on set
+type flagValue struct {
+	// field returns struct field, nil pointers to parent structs allocated only when flag set
+	field func() reflect.Value
+	typ   reflect.Type
+	def   string
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.def
+}
+
+func (v *flagValue) Set(s string) error {
+	nvalue := reflect.New(v.typ).Elem()
+	if err := fillValue(nvalue, s); err != nil {
+		return err
+	}
+	v.field().Set(nvalue)
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.typ.Kind() == reflect.Bool
+}
+
+func defineFlags(fs *flag.FlagSet, valueOf reflect.Value, tname string) error {
+	values := valueOf
+	if values.Kind() == reflect.Ptr {
+		values = values.Elem()
+	}
+
+	if values.Kind() != reflect.Struct {
+		return ErrInvalidStruct
+	}
+
+	return defineStructFlags(fs, func() reflect.Value { return values }, values.Type(), tname, "")
+}
+
+// defineStructFlags defines flags for fields of struct returned by get
+func defineStructFlags(fs *flag.FlagSet, get func() reflect.Value, fields reflect.Type, tname string, prefix string) error {
+	for idx := 0; idx < fields.NumField(); idx++ {
+		field := fields.Field(idx)
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup(tname)
+		if tag == "-" {
+			continue
+		}
+
+		fidx := idx
+		value := func() reflect.Value {
+			return get().Field(fidx)
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Struct, reflect.Ptr:
+			typ := field.Type
+			if typ.Kind() == reflect.Ptr {
+				if typ.Elem().Kind() != reflect.Struct {
+					break
+				}
+				typ = typ.Elem()
+				ptr := value
+				value = func() reflect.Value {
+					v := ptr()
+					if v.IsNil() {
+						v.Set(reflect.New(v.Type().Elem()))
+					}
+					return v.Elem()
+				}
+			}
+			nprefix := prefix
+			if ok && len(tag) > 0 {
+				nprefix = prefix + tag + "."
+			}
+			if err := defineStructFlags(fs, value, typ, tname, nprefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		name := prefix + tag
+		usage := field.Tag.Get("desc")
+		if len(usage) == 0 {
+			usage = strings.TrimSuffix(prefix, ".") + " " + field.Name
+			usage = strings.TrimSpace(usage)
+		}
+
+		// flag set may be reused by next load, so bind existing flag to the new struct
+		if f := fs.Lookup(name); f != nil {
+			if fv, ok := f.Value.(*flagValue); ok {
+				fv.field = value
+				fv.typ = field.Type
+				continue
+			}
+		}
+
+		fs.Var(&flagValue{field: value, typ: field.Type, def: field.Tag.Get("default")}, name, usage)
+	}
+
+	return nil
+}
+
+func (c *flagConfig) Save(ctx context.Context, opts ...SaveOption) error {
+	if err := DefaultBeforeSave(ctx, c); err != nil {
+		return err
+	}
+
+	if err := DefaultAfterSave(ctx, c); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *flagConfig) String() string {
+	return "flag"
+}
+
+func (c *flagConfig) Name() string {
+	return c.opts.Name
+}
+
+func (c *flagConfig) Watch(ctx context.Context, opts ...WatchOption) (Watcher, error) {
+	return nil, ErrWatcherNotImplemented
+}
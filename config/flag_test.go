@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/config"
+)
+
+type flagCfg struct {
+	Address    string         `flag:"address" desc:"server address" default:"127.0.0.1:0"`
+	Debug      bool           `flag:"debug" desc:"enable debug"`
+	Timeout    time.Duration  `flag:"timeout"`
+	SliceValue []int          `flag:"slice"`
+	Nested     *flagCfgNested `flag:"db"`
+}
+
+type flagCfgNested struct {
+	Host string `flag:"host" desc:"database host"`
+}
+
+func TestFlag(t *testing.T) {
+	ctx := context.Background()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	args := []string{"-address", "127.0.0.1:8080", "-debug", "-timeout=5s", "-slice=1,2", "-db.host", "localhost"}
+
+	conf := &flagCfg{}
+	cfg := config.NewFlagConfig(config.Struct(conf), config.FlagSet(fs), config.FlagArgs(args))
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Address != "127.0.0.1:8080" || !conf.Debug || conf.Timeout != 5*time.Second || len(conf.SliceValue) != 2 {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+	if conf.Nested == nil || conf.Nested.Host != "localhost" {
+		t.Fatalf("invalid nested config %#+v", conf.Nested)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	fs.SetOutput(buf)
+	fs.PrintDefaults()
+	if help := buf.String(); !strings.Contains(help, "server address (default 127.0.0.1:0)") || !strings.Contains(help, "-db.host") {
+		t.Fatalf("invalid help text %s", help)
+	}
+
+	// load again with the same flag set
+	conf = &flagCfg{}
+	if err := cfg.Load(ctx, config.LoadStruct(conf)); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Address != "127.0.0.1:8080" {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+}
+
+func TestFlagNilNested(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	conf := &flagCfg{}
+	cfg := config.NewFlagConfig(config.Struct(conf), config.FlagSet(fs), config.FlagArgs([]string{"-debug"}))
+	if err := cfg.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !conf.Debug {
+		t.Fatalf("invalid config %#+v", conf)
+	}
+	// nested struct not allocated without its flags
+	if conf.Nested != nil {
+		t.Fatalf("nested config must be nil, got %#+v", conf.Nested)
+	}
+}
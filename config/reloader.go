@@ -0,0 +1,228 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SubscribeFunc called after config struct replaced by new version
+type SubscribeFunc func(ctx context.Context, oldValue interface{}, newValue interface{})
+
+// Reloader owns config struct and atomically replaces it on changes.
+// Readers must use Get and never modify returned struct.
+type Reloader struct {
+	c     Config
+	value atomic.Value
+	subs  []SubscribeFunc
+	mu    sync.Mutex
+}
+
+// NewReloader returns Reloader for config c, initial value taken from c.Options().Struct
+func NewReloader(c Config) (*Reloader, error) {
+	src := c.Options().Struct
+	if src == nil || reflect.ValueOf(src).Kind() != reflect.Ptr || reflect.ValueOf(src).Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidStruct
+	}
+	r := &Reloader{c: c}
+	r.value.Store(src)
+	return r, nil
+}
+
+// Get returns current config struct
+func (r *Reloader) Get() interface{} {
+	return r.value.Load()
+}
+
+// Subscribe registers func that called with old and new struct after each successful reload
+func (r *Reloader) Subscribe(fn SubscribeFunc) {
+	r.mu.Lock()
+	r.subs = append(r.subs, fn)
+	r.mu.Unlock()
+}
+
+// Apply applies changes to a copy of current struct, runs AfterLoad hooks and Validate
+// on it and replaces current struct only if all of them succeed.
+// Subscribers called without lock held, so they can call Subscribe and Apply
+func (r *Reloader) Apply(ctx context.Context, changes map[string]interface{}) error {
+	oldValue, newValue, subs, err := r.apply(ctx, changes)
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range subs {
+		fn(ctx, oldValue, newValue)
+	}
+
+	return nil
+}
+
+// apply replaces current struct under lock and returns old and new structs with subscribers to notify
+func (r *Reloader) apply(ctx context.Context, changes map[string]interface{}) (interface{}, interface{}, []SubscribeFunc, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldValue := r.value.Load()
+	newValue := deepCopy(reflect.ValueOf(oldValue)).Interface()
+
+	for path, val := range changes {
+		if err := setFieldByPath(newValue, path, val); err != nil {
+			return nil, nil, nil, fmt.Errorf("config reload %s: %w", path, err)
+		}
+	}
+
+	// hooks works with options struct, so point config to the new struct while running them
+	if err := r.c.Init(Struct(newValue)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	err := DefaultAfterLoad(ctx, r.c)
+	if err == nil {
+		err = Validate(ctx, newValue)
+	}
+
+	if err != nil {
+		r.c.Options().Logger.Errorf(ctx, "%s reload error, keep previous config: %v", r.c.String(), err)
+		_ = r.c.Init(Struct(oldValue))
+		return nil, nil, nil, err
+	}
+
+	r.value.Store(newValue)
+
+	subs := make([]SubscribeFunc, len(r.subs))
+	copy(subs, r.subs)
+
+	return oldValue, newValue, subs, nil
+}
+
+// Watch watches config for changes and applies them until context done or watcher stopped.
+// Errors from Apply logged and does not stop watching.
+func (r *Reloader) Watch(ctx context.Context, opts ...WatchOption) error {
+	w, err := r.c.Watch(ctx, append(opts, WatchStruct(r.Get()))...)
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	go func() {
+		<-ctx.Done()
+		_ = w.Stop()
+	}()
+
+	for {
+		changes, err := w.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		_ = r.Apply(ctx, changes)
+	}
+}
+
+// setFieldByPath sets struct field by dot separated path, nil struct pointers allocated on the way
+func setFieldByPath(src interface{}, path string, val interface{}) error {
+	value := reflect.ValueOf(src)
+
+	for _, name := range strings.Split(path, ".") {
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !value.CanSet() {
+					return ErrInvalidStruct
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			return ErrInvalidStruct
+		}
+		value = value.FieldByNameFunc(func(fname string) bool {
+			return strings.EqualFold(fname, name)
+		})
+		if !value.IsValid() {
+			return fmt.Errorf("field %s not found", name)
+		}
+	}
+
+	if !value.CanSet() {
+		return ErrInvalidStruct
+	}
+
+	if val == nil {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+
+	nvalue := reflect.ValueOf(val)
+	switch {
+	case nvalue.Type().AssignableTo(value.Type()):
+		value.Set(nvalue)
+	case nvalue.Type().ConvertibleTo(value.Type()):
+		value.Set(nvalue.Convert(value.Type()))
+	case nvalue.Kind() == reflect.String:
+		value.Set(reflect.Zero(value.Type()))
+		return fillValue(value, nvalue.String())
+	default:
+		return fmt.Errorf("can't assign %s to %s", nvalue.Type(), value.Type())
+	}
+
+	return nil
+}
+
+// deepCopy returns copy of value that does not share pointers, maps and slices with it
+func deepCopy(src reflect.Value) reflect.Value {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.New(src.Type().Elem())
+		dst.Elem().Set(deepCopy(src.Elem()))
+		return dst
+	case reflect.Interface:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(deepCopy(src.Elem()))
+		return dst
+	case reflect.Struct:
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(src)
+		for idx := 0; idx < src.NumField(); idx++ {
+			if fld := dst.Field(idx); fld.CanSet() {
+				fld.Set(deepCopy(src.Field(idx)))
+			}
+		}
+		return dst
+	case reflect.Slice:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for idx := 0; idx < src.Len(); idx++ {
+			dst.Index(idx).Set(deepCopy(src.Index(idx)))
+		}
+		return dst
+	case reflect.Map:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return dst
+	default:
+		return src
+	}
+}
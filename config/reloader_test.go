@@ -0,0 +1,97 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.unistack.org/micro/v3/config"
+)
+
+type reloadCfg struct {
+	Nested      *reloadCfgNested
+	StringValue string
+	IntValue    int
+}
+
+type reloadCfgNested struct {
+	Values []string
+}
+
+func (c *reloadCfg) Validate() error {
+	if c.IntValue < 0 {
+		return errors.New("negative IntValue")
+	}
+	return nil
+}
+
+func TestReloader(t *testing.T) {
+	ctx := context.Background()
+	conf := &reloadCfg{StringValue: "string_value", IntValue: 1, Nested: &reloadCfgNested{Values: []string{"a"}}}
+
+	var afterLoad int
+	alfn := func(_ context.Context, c config.Config) error {
+		afterLoad++
+		return nil
+	}
+
+	r, err := config.NewReloader(config.NewConfig(config.Struct(conf), config.AfterLoad(alfn)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var oldValue, newValue *reloadCfg
+	r.Subscribe(func(_ context.Context, o interface{}, n interface{}) {
+		oldValue, newValue = o.(*reloadCfg), n.(*reloadCfg)
+	})
+
+	if err = r.Apply(ctx, map[string]interface{}{"IntValue": 2, "Nested.Values": []string{"b", "c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cur := r.Get().(*reloadCfg)
+	if cur.IntValue != 2 || len(cur.Nested.Values) != 2 || cur.StringValue != "string_value" {
+		t.Fatalf("invalid config %#+v", cur)
+	}
+	if conf.IntValue != 1 || len(conf.Nested.Values) != 1 {
+		t.Fatalf("previous config modified %#+v", conf)
+	}
+	if oldValue != conf || newValue != cur || afterLoad != 1 {
+		t.Fatalf("subscriber not notified %v %v %d", oldValue, newValue, afterLoad)
+	}
+
+	if err = r.Apply(ctx, map[string]interface{}{"IntValue": -1}); err == nil {
+		t.Fatal("invalid config must be rejected")
+	}
+	if r.Get().(*reloadCfg) != cur || cur.IntValue != 2 {
+		t.Fatalf("previous config must be kept %#+v", r.Get())
+	}
+}
+
+func TestReloaderSubscriberReentrant(t *testing.T) {
+	ctx := context.Background()
+	conf := &reloadCfg{IntValue: 1}
+
+	r, err := config.NewReloader(config.NewConfig(config.Struct(conf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	r.Subscribe(func(ctx context.Context, _ interface{}, n interface{}) {
+		calls++
+		r.Subscribe(func(context.Context, interface{}, interface{}) {})
+		if n.(*reloadCfg).IntValue == 2 {
+			if err := r.Apply(ctx, map[string]interface{}{"IntValue": 3}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	if err = r.Apply(ctx, map[string]interface{}{"IntValue": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if cur := r.Get().(*reloadCfg); cur.IntValue != 3 || calls != 2 {
+		t.Fatalf("invalid config %#+v calls %d", cur, calls)
+	}
+}
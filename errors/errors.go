@@ -9,6 +9,9 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"go.unistack.org/micro/v3/metadata"
 )
 
 var (
@@ -26,6 +29,8 @@ var (
 	ErrTimeout = &Error{Code: 408}
 	// ErrConflict returns then request create duplicate resource
 	ErrConflict = &Error{Code: 409}
+	// ErrTooManyRequests returns then request rejected by rate or concurrency limit
+	ErrTooManyRequests = &Error{Code: 429}
 	// ErrInternalServerError returns then server cant process request because of internal error
 	ErrInternalServerError = &Error{Code: 500}
 	// ErNotImplemented returns then server does not have desired endpoint method
@@ -46,6 +51,8 @@ type Error struct {
 	Detail string
 	// Status usually holds text of http status
 	Status string
+	// Metadata holds additional error info like metadata.HeaderRetryAfter
+	Metadata metadata.Metadata
 	// Code holds error code
 	Code int32
 }
@@ -163,6 +170,16 @@ func Conflict(id, format string, args ...interface{}) error {
 	}
 }
 
+// TooManyRequests generates a 429 error.
+func TooManyRequests(id, format string, args ...interface{}) error {
+	return &Error{
+		ID:     id,
+		Code:   429,
+		Detail: fmt.Sprintf(format, args...),
+		Status: http.StatusText(429),
+	}
+}
+
 // InternalServerError generates a 500 error.
 func InternalServerError(id, format string, args ...interface{}) error {
 	return &Error{
@@ -281,9 +298,22 @@ func (e *Error) Reset() {
 	*e = Error{}
 }
 
+// jsonError holds wire representation of the error
+type jsonError struct {
+	ID       string            `json:"id"`
+	Detail   string            `json:"detail"`
+	Status   string            `json:"status"`
+	Code     int32             `json:"code"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
 // String returns error as string
 func (e *Error) String() string {
-	return fmt.Sprintf(`{"id":"%s","detail":"%s","status":"%s","code":%d}`, addslashes(e.ID), addslashes(e.Detail), addslashes(e.Status), e.Code)
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(jsonError{ID: e.ID, Detail: e.Detail, Status: e.Status, Code: e.Code, Metadata: e.Metadata})
+	return strings.TrimSuffix(buf.String(), "\n")
 }
 
 // Marshal returns error data
@@ -293,50 +323,45 @@ func (e *Error) Marshal() ([]byte, error) {
 
 // Unmarshal set error data
 func (e *Error) Unmarshal(data []byte) error {
-	str := string(data)
 	if len(data) < 41 {
 		return fmt.Errorf("invalid data")
 	}
-	parts := strings.FieldsFunc(str[1:len(str)-1], func(r rune) bool {
-		return r == ','
-	})
-	for _, part := range parts {
-		nparts := strings.FieldsFunc(part, func(r rune) bool {
-			return r == ':'
-		})
-		for idx := 0; idx < len(nparts)/2; idx += 2 {
-			val := strings.Trim(nparts[idx+1], `"`)
-			if len(val) == 0 {
-				continue
-			}
-			switch {
-			case nparts[idx] == `"id"`:
-				e.ID = val
-			case nparts[idx] == `"detail"`:
-				e.Detail = val
-			case nparts[idx] == `"status"`:
-				e.Status = val
-			case nparts[idx] == `"code"`:
-				c, err := strconv.ParseInt(val, 10, 32)
-				if err != nil {
-					return err
-				}
-				e.Code = int32(c)
-			}
-			idx++
-		}
+	var je jsonError
+	if err := json.Unmarshal(data, &je); err != nil {
+		return err
+	}
+	e.ID = je.ID
+	e.Detail = je.Detail
+	e.Status = je.Status
+	e.Code = je.Code
+	if len(je.Metadata) > 0 {
+		e.Metadata = metadata.Metadata(je.Metadata)
 	}
 	return nil
 }
 
-func addslashes(str string) string {
-	var buf bytes.Buffer
-	for _, char := range str {
-		switch char {
-		case '\'', '"', '\\':
-			buf.WriteRune('\\')
+// RetryAfter returns delay before retry requested by metadata.HeaderRetryAfter of the error,
+// header holds seconds or http date
+func RetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	v, ok := FromError(err).Metadata.Get(metadata.HeaderRetryAfter)
+	if !ok || v == "" {
+		return 0, false
+	}
+	if n, nerr := strconv.ParseInt(v, 10, 64); nerr == nil {
+		if n < 0 {
+			return 0, false
 		}
-		buf.WriteRune(char)
+		return time.Duration(n) * time.Second, true
+	}
+	t, terr := http.ParseTime(v)
+	if terr != nil {
+		return 0, false
+	}
+	if d := time.Until(t); d > 0 {
+		return d, true
 	}
-	return buf.String()
+	return 0, true
 }
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/metadata"
 )
 
 func TestMarshalJSON(t *testing.T) {
@@ -112,3 +115,38 @@ func TestCodeIn(t *testing.T) {
 		t.Fatalf("CodeIn not works: %v", err)
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	err := &Error{ID: "test", Code: 503, Detail: "overloaded", Metadata: metadata.Metadata{metadata.HeaderRetryAfter: "2"}}
+
+	pe := Parse(err.Error())
+	if pe.Code != 503 || pe.Detail != "overloaded" || pe.Metadata[metadata.HeaderRetryAfter] != "2" {
+		t.Fatalf("invalid parsed error %#+v", pe)
+	}
+
+	if d, ok := RetryAfter(er.New(err.Error())); !ok || d != 2*time.Second {
+		t.Fatalf("invalid retry after %v %v", d, ok)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := RetryAfter(&Error{Metadata: metadata.Metadata{metadata.HeaderRetryAfter: date}}); !ok || d <= 59*time.Minute {
+		t.Fatalf("invalid retry after %v %v", d, ok)
+	}
+
+	if _, ok := RetryAfter(InternalServerError("test", "error")); ok {
+		t.Fatal("error without metadata has no retry after")
+	}
+}
+
+func TestUnmarshalMetadata(t *testing.T) {
+	detail := `can't parse "a,b:c",` + `"metadata":{"x":"y"}}`
+	err := &Error{ID: "test", Code: 429, Detail: detail, Status: "<status>", Metadata: metadata.Metadata{metadata.HeaderRetryAfter: "1"}}
+
+	pe := Parse(err.Error())
+	if pe.ID != "test" || pe.Code != 429 || pe.Detail != detail || pe.Status != "<status>" {
+		t.Fatalf("invalid parsed error %#+v", pe)
+	}
+	if len(pe.Metadata) != 1 || pe.Metadata[metadata.HeaderRetryAfter] != "1" {
+		t.Fatalf("invalid parsed metadata %v", pe.Metadata)
+	}
+}
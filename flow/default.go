@@ -2,8 +2,12 @@ package flow
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/silas/dag"
 	"go.unistack.org/micro/v3/client"
@@ -14,6 +18,17 @@ import (
 	"go.unistack.org/micro/v3/util/id"
 )
 
+func init() {
+	RegisterStep(&microCallStep{})
+	RegisterStep(&microPublishStep{})
+}
+
+var (
+	_ Flow             = &microFlow{}
+	_ WorkflowRemover  = &microFlow{}
+	_ ExecutionHistory = &microFlow{}
+)
+
 type microFlow struct {
 	opts Options
 }
@@ -37,21 +52,39 @@ func (w *microWorkflow) Steps() ([][]Step, error) {
 }
 
 func (w *microWorkflow) Status() Status {
+	w.RLock()
+	defer w.RUnlock()
 	return w.status
 }
 
+func (w *microWorkflow) setStatus(status Status) {
+	w.Lock()
+	w.status = status
+	w.Unlock()
+}
+
 func (w *microWorkflow) AppendSteps(steps ...Step) error {
 	w.Lock()
 
 	for _, s := range steps {
 		w.steps[s.String()] = s
-		w.g.Add(s)
+	}
+
+	handlers := handlerSteps(w.steps)
+	for _, s := range steps {
+		if _, ok := handlers[s.String()]; !ok {
+			w.g.Add(s)
+		}
 	}
 
 	for _, dst := range steps {
+		if _, ok := handlers[dst.String()]; ok {
+			continue
+		}
 		for _, req := range dst.Requires() {
 			src, ok := w.steps[req]
 			if !ok {
+				w.Unlock()
 				return ErrStepNotExists
 			}
 			w.g.Connect(dag.BasicEdge(src, dst))
@@ -70,17 +103,40 @@ func (w *microWorkflow) AppendSteps(steps ...Step) error {
 	return nil
 }
 
+// handlerSteps returns ids of steps used as fallback or compensation by other steps
+func handlerSteps(steps map[string]Step) map[string]struct{} {
+	handlers := make(map[string]struct{})
+	for _, s := range steps {
+		if opts := s.Options(); opts.Fallback != "" {
+			handlers[opts.Fallback] = struct{}{}
+		}
+		if opts := s.Options(); opts.Compensate != "" {
+			handlers[opts.Compensate] = struct{}{}
+		}
+	}
+	return handlers
+}
+
 func (w *microWorkflow) RemoveSteps(steps ...Step) error {
 	// TODO: handle case when some step requires or required by removed step
 
 	w.Lock()
+	defer w.Unlock()
+
+	// fallback and compensation steps not part of dag
+	handlers := handlerSteps(w.steps)
 
 	for _, s := range steps {
 		delete(w.steps, s.String())
-		w.g.Remove(s)
+		if _, ok := handlers[s.String()]; !ok {
+			w.g.Remove(s)
+		}
 	}
 
 	for _, dst := range steps {
+		if _, ok := handlers[dst.String()]; ok {
+			continue
+		}
 		for _, req := range dst.Requires() {
 			src, ok := w.steps[req]
 			if !ok {
@@ -91,14 +147,11 @@ func (w *microWorkflow) RemoveSteps(steps ...Step) error {
 	}
 
 	if err := w.g.Validate(); err != nil {
-		w.Unlock()
 		return err
 	}
 
 	w.g.TransitiveReduction()
 
-	w.Unlock()
-
 	return nil
 }
 
@@ -149,18 +202,71 @@ func (w *microWorkflow) getSteps(start string, reverse bool) ([][]Step, error) {
 }
 
 func (w *microWorkflow) Abort(ctx context.Context, id string) error {
-	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+w.opts.Store.Options().Separator+id)
-	return workflowStore.Write(ctx, "status", &codec.Frame{Data: []byte(StatusAborted.String())})
+	return w.writeStatus(ctx, id, StatusAborted, nil)
 }
 
+// Suspend stops execution before next step, worker that runs execution waits for running steps
+// and then releases execution lease, so execution can be resumed after that
 func (w *microWorkflow) Suspend(ctx context.Context, id string) error {
-	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+w.opts.Store.Options().Separator+id)
-	return workflowStore.Write(ctx, "status", &codec.Frame{Data: []byte(StatusSuspend.String())})
+	return w.writeStatus(ctx, id, StatusSuspend, nil)
 }
 
 func (w *microWorkflow) Resume(ctx context.Context, id string) error {
-	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+w.opts.Store.Options().Separator+id)
-	return workflowStore.Write(ctx, "status", &codec.Frame{Data: []byte(StatusRunning.String())})
+	if w.opts.Store == nil {
+		return ErrMissingStore
+	}
+
+	sep := w.opts.Store.Options().Separator
+	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+sep+id)
+
+	if err := store.NewNamespaceStore(w.opts.Store, "executions").Exists(ctx, w.id+sep+id); err == store.ErrNotFound {
+		return ErrExecutionNotExists
+	} else if err != nil {
+		return err
+	}
+
+	buf := &codec.Frame{}
+	if err := workflowStore.Read(ctx, "status", buf); err != nil {
+		return err
+	}
+	if StringStatus[string(buf.Data)] == StatusSuccess {
+		return nil
+	}
+
+	buf = &codec.Frame{}
+	if err := workflowStore.Read(ctx, "options", buf); err != nil {
+		return err
+	}
+	eopts := executionOptions{}
+	if err := json.Unmarshal(buf.Data, &eopts); err != nil {
+		return err
+	}
+
+	req := &Message{}
+	if err := workflowStore.Read(ctx, "req", req); err != nil {
+		return err
+	}
+
+	steps, err := w.getSteps(eopts.Start, eopts.Reverse)
+	if err != nil {
+		return err
+	}
+
+	return w.execute(ctx, id, req, steps, []ExecuteOption{
+		ExecuteReverse(eopts.Reverse),
+		ExecuteMaxConcurrency(eopts.MaxConcurrency),
+		ExecuteContinueOnError(eopts.ContinueOnError),
+		ExecuteTimeout(eopts.Timeout),
+	})
+}
+
+// executionOptions holds execute options needed to resume execution
+type executionOptions struct {
+	Start           string        `json:"start,omitempty"`
+	Timeout         time.Duration `json:"timeout,omitempty"`
+	MaxConcurrency  int           `json:"max_concurrency,omitempty"`
+	Reverse         bool          `json:"reverse,omitempty"`
+	ContinueOnError bool          `json:"continue_on_error,omitempty"`
 }
 
 func (w *microWorkflow) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (string, error) {
@@ -175,177 +281,474 @@ func (w *microWorkflow) Execute(ctx context.Context, req *Message, opts ...Execu
 	}
 	w.Unlock()
 
+	if w.opts.Store == nil {
+		return "", ErrMissingStore
+	}
+
 	eid, err := id.New()
 	if err != nil {
 		return "", err
 	}
 
-	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+w.opts.Store.Options().Separator+eid)
-	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+w.opts.Store.Options().Separator+eid)
+	sep := w.opts.Store.Options().Separator
+	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+sep+eid)
 
 	options := NewExecuteOptions(opts...)
 
 	steps, err := w.getSteps(options.Start, options.Reverse)
 	if err != nil {
-		if werr := workflowStore.Write(w.opts.Context, "status", &codec.Frame{Data: []byte(StatusPending.String())}); werr != nil {
+		if werr := w.writeStatus(w.opts.Context, eid, StatusPending, err); werr != nil {
 			w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
 		}
 		return "", err
 	}
 
-	var wg sync.WaitGroup
-	cherr := make(chan error, 1)
-	chstatus := make(chan Status, 1)
+	// persist all that needed to resume execution by other worker
+	buf, err := json.Marshal(executionOptions{
+		Start:           options.Start,
+		Timeout:         options.Timeout,
+		Reverse:         options.Reverse,
+		MaxConcurrency:  options.MaxConcurrency,
+		ContinueOnError: options.ContinueOnError,
+	})
+	if err != nil {
+		return "", err
+	}
+	if werr := workflowStore.Write(w.opts.Context, "options", &codec.Frame{Data: buf}); werr != nil {
+		w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+		return eid, werr
+	}
+	if werr := workflowStore.Write(w.opts.Context, "req", req); werr != nil {
+		w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+		return eid, werr
+	}
+	executionStore := store.NewNamespaceStore(w.opts.Store, "executions")
+	if werr := executionStore.Write(w.opts.Context, w.id+sep+eid, &codec.Frame{Data: []byte(time.Now().UTC().Format(time.RFC3339Nano))}); werr != nil {
+		w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+		return eid, werr
+	}
+	for idx := range steps {
+		for nidx := range steps[idx] {
+			if werr := w.writeStepStatus(ctx, eid, steps[idx][nidx], StatusPending, nil, nil); werr != nil {
+				return eid, werr
+			}
+		}
+	}
 
-	nctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	return eid, w.execute(ctx, eid, req, steps, opts)
+}
+
+// execute takes execution lease and runs steps, steps already succeeded in this execution are skipped
+func (w *microWorkflow) execute(ctx context.Context, eid string, req *Message, steps [][]Step, opts []ExecuteOption) error {
+
+	owner, err := id.New()
+	if err != nil {
+		return err
+	}
+
+	if err = w.acquireLease(ctx, eid, owner); err != nil {
+		return err
+	}
+
+	if werr := w.writeStatus(w.opts.Context, eid, StatusRunning, nil); werr != nil {
+		w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+		_ = w.releaseLease(w.opts.Context, eid, owner)
+		return werr
+	}
+	w.setStatus(StatusRunning)
 
-	nopts := make([]ExecuteOption, 0, len(opts)+5)
+	options := NewExecuteOptions(opts...)
 
+	nopts := make([]ExecuteOption, 0, len(opts)+8)
 	nopts = append(nopts,
 		ExecuteClient(w.opts.Client),
 		ExecuteTracer(w.opts.Tracer),
 		ExecuteLogger(w.opts.Logger),
 		ExecuteMeter(w.opts.Meter),
+		ExecuteStore(w.opts.Store),
+		ExecuteEventTopic(w.opts.EventTopic),
+		ExecuteLeaseTTL(w.opts.LeaseTTL),
+		ExecuteSync(w.opts.Sync),
 	)
 	nopts = append(nopts, opts...)
-	done := make(chan struct{})
 
-	if werr := workflowStore.Write(w.opts.Context, "status", &codec.Frame{Data: []byte(StatusRunning.String())}); werr != nil {
-		w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
-		return eid, werr
+	nctx, cancel := context.WithCancel(ctx)
+	cherr := make(chan error, 1)
+
+	go func() {
+		defer cancel()
+
+		lctx, lcancel := context.WithCancel(nctx)
+		go w.renewLease(lctx, eid, owner)
+
+		status, err := w.run(nctx, eid, req, steps, options, nopts)
+
+		lcancel()
+		if lerr := w.releaseLease(w.opts.Context, eid, owner); lerr != nil {
+			w.opts.Logger.Errorf(w.opts.Context, "store error: %v", lerr)
+		}
+
+		// status suspended or aborted by other call already in store
+		switch {
+		case nctx.Err() != nil:
+			status, err = StatusAborted, nctx.Err()
+		case err != nil:
+			status = StatusFailure
+		}
+		if nctx.Err() != nil || status == StatusFailure || status == StatusSuccess {
+			if werr := w.writeStatus(w.opts.Context, eid, status, err); werr != nil {
+				w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+			}
+		}
+		w.setStatus(status)
+
+		cherr <- err
+	}()
+
+	if options.Async {
+		return nil
+	}
+
+	logger.Tracef(ctx, "wait for finish or error")
+	return <-cherr
+}
+
+// run executes steps in parallel as soon as their required steps succeeded,
+// until all done, step failed or execution status changed
+func (w *microWorkflow) run(ctx context.Context, eid string, req *Message, steps [][]Step, options ExecuteOptions, opts []ExecuteOption) (Status, error) {
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+sep+eid)
+
+	var ready []Step
+	all := make(map[string]Step)
+	for idx := range steps {
+		for nidx := range steps[idx] {
+			all[steps[idx][nidx].ID()] = steps[idx][nidx]
+		}
 	}
+
+	// count not finished required steps, in reverse mode dependents must finish first
+	pending := make(map[string]int, len(all))
+	parents := make(map[string]int, len(all))
+	next := make(map[string][]Step, len(all))
 	for idx := range steps {
 		for nidx := range steps[idx] {
 			cstep := steps[idx][nidx]
-			if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusPending.String())}); werr != nil {
-				return eid, werr
+			for _, rid := range cstep.Requires() {
+				rstep, ok := all[rid]
+				if !ok {
+					continue
+				}
+				if options.Reverse {
+					pending[rid]++
+					parents[rid]++
+					next[cstep.ID()] = append(next[cstep.ID()], rstep)
+				} else {
+					pending[cstep.ID()]++
+					parents[cstep.ID()]++
+					next[rid] = append(next[rid], cstep)
+				}
+			}
+		}
+	}
+	for idx := range steps {
+		for nidx := range steps[idx] {
+			if pending[steps[idx][nidx].ID()] == 0 {
+				ready = append(ready, steps[idx][nidx])
 			}
 		}
 	}
 
-	go func() {
-		for idx := range steps {
-			for nidx := range steps[idx] {
-				wStatus := &codec.Frame{}
-				if werr := workflowStore.Read(w.opts.Context, "status", wStatus); werr != nil {
-					cherr <- werr
-					return
+	type result struct {
+		err  error
+		step Step
+	}
+
+	// steps not selected by branch steps and steps all required steps of that skipped
+	skipped := make(map[string]bool)
+	executed := make(map[string]int, len(all))
+
+	// wait steps subscribed before any step executed, so replies to earlier steps not lost
+	wctx, unsubscribe, err := subscribeWaits(ctx, all, NewExecuteOptions(opts...))
+	if err != nil {
+		return StatusFailure, err
+	}
+	defer unsubscribe()
+
+	sctx, cancel := context.WithCancel(wctx)
+	defer cancel()
+
+	results := make(chan result, len(all))
+	status := StatusSuccess
+	running := 0
+	stop := false
+
+	// completed steps in order of completion used to run compensations
+	var completed []Step
+	release := func(step Step, skip bool) {
+		if !skip {
+			completed = append(completed, step)
+		}
+		for _, nstep := range next[step.ID()] {
+			if !skip {
+				executed[nstep.ID()]++
+			}
+			if pending[nstep.ID()]--; pending[nstep.ID()] == 0 {
+				ready = append(ready, nstep)
+			}
+		}
+	}
+	done := func(step Step) error {
+		bstep, ok := step.(*microBranchStep)
+		if ok {
+			rsp := &Message{}
+			if rerr := stepStore.Read(ctx, step.ID()+sep+"rsp", rsp); rerr != nil {
+				return rerr
+			}
+			id, berr := bstep.branch(rsp)
+			if berr != nil {
+				return berr
+			}
+			for _, bid := range bstep.branches() {
+				if bid != id {
+					skipped[bid] = true
+				}
+			}
+		}
+		release(step, false)
+		return nil
+	}
+
+	for {
+		for len(ready) > 0 && !stop && (options.MaxConcurrency <= 0 || running < options.MaxConcurrency) {
+			if ctx.Err() != nil {
+				stop = true
+				break
+			}
+			wStatus := &codec.Frame{}
+			if werr := workflowStore.Read(w.opts.Context, "status", wStatus); werr != nil {
+				status, err, stop = StatusFailure, werr, true
+				cancel()
+				break
+			}
+			if wstatus := StringStatus[string(wStatus.Data)]; wstatus != StatusRunning {
+				status, stop = wstatus, true
+				break
+			}
+
+			cstep := ready[0]
+			ready = ready[1:]
+
+			if skipped[cstep.ID()] || (parents[cstep.ID()] > 0 && executed[cstep.ID()] == 0) {
+				if w.opts.Logger.V(logger.TraceLevel) {
+					w.opts.Logger.Tracef(ctx, "skipped %v", cstep)
 				}
-				if status := StringStatus[string(wStatus.Data)]; status != StatusRunning {
-					chstatus <- status
-					return
+				if werr := w.writeStepStatus(ctx, eid, cstep, StatusSkipped, nil, nil); werr != nil {
+					status, err, stop = StatusFailure, werr, true
+					cancel()
+					break
 				}
+				release(cstep, true)
+				continue
+			}
+
+			succeeded, werr := w.stepSucceeded(ctx, stepStore, cstep)
+			if werr != nil {
+				status, err, stop = StatusFailure, werr, true
+				cancel()
+				break
+			}
+			if succeeded {
 				if w.opts.Logger.V(logger.TraceLevel) {
-					w.opts.Logger.Tracef(nctx, "will be executed %v", steps[idx][nidx])
+					w.opts.Logger.Tracef(ctx, "already executed %v", cstep)
 				}
-				cstep := steps[idx][nidx]
-				// nolint: nestif
-				if len(cstep.Requires()) == 0 {
-					wg.Add(1)
-					go func(step Step) {
-						defer wg.Done()
-						if werr := stepStore.Write(ctx, step.ID()+w.opts.Store.Options().Separator+"req", req); werr != nil {
-							cherr <- werr
-							return
-						}
-						if werr := stepStore.Write(ctx, step.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusRunning.String())}); werr != nil {
-							cherr <- werr
-							return
-						}
-						rsp, serr := step.Execute(nctx, req, nopts...)
-						if serr != nil {
-							step.SetStatus(StatusFailure)
-							if werr := stepStore.Write(ctx, step.ID()+w.opts.Store.Options().Separator+"rsp", serr); werr != nil && w.opts.Logger.V(logger.ErrorLevel) {
-								w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-							}
-							if werr := stepStore.Write(ctx, step.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusFailure.String())}); werr != nil && w.opts.Logger.V(logger.ErrorLevel) {
-								w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-							}
-							cherr <- serr
-							return
-						}
-						if werr := stepStore.Write(ctx, step.ID()+w.opts.Store.Options().Separator+"rsp", rsp); werr != nil {
-							w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-							cherr <- werr
-							return
-						}
-						if werr := stepStore.Write(ctx, step.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusSuccess.String())}); werr != nil {
-							w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-							cherr <- werr
-							return
-						}
-					}(cstep)
-					wg.Wait()
-				} else {
-					if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"req", req); werr != nil {
-						cherr <- werr
-						return
-					}
-					if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusRunning.String())}); werr != nil {
-						cherr <- werr
-						return
-					}
-					rsp, serr := cstep.Execute(nctx, req, nopts...)
-					if serr != nil {
-						cstep.SetStatus(StatusFailure)
-						if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"rsp", serr); werr != nil && w.opts.Logger.V(logger.ErrorLevel) {
-							w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-						}
-						if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusFailure.String())}); werr != nil && w.opts.Logger.V(logger.ErrorLevel) {
-							w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-						}
-						cherr <- serr
-						return
-					}
-					if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"rsp", rsp); werr != nil {
-						w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
-						cherr <- werr
-						return
-					}
-					if werr := stepStore.Write(ctx, cstep.ID()+w.opts.Store.Options().Separator+"status", &codec.Frame{Data: []byte(StatusSuccess.String())}); werr != nil {
-						cherr <- werr
-						return
+				if werr = done(cstep); werr != nil {
+					status, err, stop = StatusFailure, werr, true
+					cancel()
+					break
+				}
+				continue
+			}
+
+			if w.opts.Logger.V(logger.TraceLevel) {
+				w.opts.Logger.Tracef(ctx, "will be executed %v", cstep)
+			}
+			running++
+			go func(step Step) {
+				sreq, serr := w.stepRequest(sctx, stepStore, step, req)
+				if serr == nil {
+					_, serr = w.executeStep(sctx, eid, step, sreq, opts)
+				}
+				if serr != nil && sctx.Err() == nil && step.Options().Fallback != "" {
+					if ferr := w.executeFallback(sctx, eid, step, sreq, opts); ferr != nil {
+						w.opts.Logger.Errorf(sctx, "step %s fallback error: %v", step.ID(), ferr)
+					} else {
+						serr = nil
 					}
 				}
+				results <- result{step: step, err: serr}
+			}(cstep)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		if res.err != nil {
+			if err == nil {
+				status, err = StatusFailure, res.err
 			}
+			// dependents of failed step never become ready
+			if !options.ContinueOnError {
+				stop = true
+				cancel()
+			}
+			continue
 		}
-		close(done)
-	}()
+		if derr := done(res.step); derr != nil {
+			if err == nil {
+				status, err = StatusFailure, derr
+			}
+			stop = true
+			cancel()
+		}
+	}
 
-	if options.Async {
-		return eid, nil
+	if err == nil && status == StatusSuccess && ctx.Err() != nil {
+		return StatusAborted, ctx.Err()
 	}
 
-	logger.Tracef(ctx, "wait for finish or error")
-	select {
-	case <-nctx.Done():
-		err = nctx.Err()
-	case cerr := <-cherr:
-		err = cerr
-	case <-done:
-		close(cherr)
-	case <-chstatus:
-		close(chstatus)
-		return eid, nil
-	}
-
-	switch {
-	case nctx.Err() != nil:
-		if werr := workflowStore.Write(w.opts.Context, "status", &codec.Frame{Data: []byte(StatusAborted.String())}); werr != nil {
-			w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+	if err != nil && ctx.Err() == nil {
+		w.compensate(ctx, eid, completed, opts)
+	}
+
+	return status, err
+}
+
+// stepSucceeded checks that step or its fallback already succeeded in the execution
+func (w *microWorkflow) stepSucceeded(ctx context.Context, stepStore store.Store, step Step) (bool, error) {
+	sep := w.opts.Store.Options().Separator
+	for _, id := range []string{step.ID(), step.Options().Fallback} {
+		if id == "" {
+			continue
 		}
-	case err == nil:
-		if werr := workflowStore.Write(w.opts.Context, "status", &codec.Frame{Data: []byte(StatusSuccess.String())}); werr != nil {
-			w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+		buf := &codec.Frame{}
+		if err := stepStore.Read(ctx, id+sep+"status", buf); err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return false, err
 		}
-	case err != nil:
-		if werr := workflowStore.Write(w.opts.Context, "status", &codec.Frame{Data: []byte(StatusFailure.String())}); werr != nil {
-			w.opts.Logger.Errorf(w.opts.Context, "store error: %v", werr)
+		if StringStatus[string(buf.Data)] == StatusSuccess {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// executeFallback runs fallback of the failed step, fallback response stored as response of the step
+func (w *microWorkflow) executeFallback(ctx context.Context, eid string, step Step, req *Message, opts []ExecuteOption) error {
+	w.RLock()
+	fstep, ok := w.steps[step.Options().Fallback]
+	w.RUnlock()
+	if !ok {
+		return ErrStepNotExists
+	}
+
+	rsp, err := w.executeStep(ctx, eid, fstep, req, opts)
+	if err != nil {
+		return err
+	}
+
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+
+	return stepStore.Write(ctx, step.ID()+sep+"rsp", rsp)
+}
+
+// compensate runs compensation steps of completed steps in reverse order,
+// compensation errors logged and does not stop other compensations
+func (w *microWorkflow) compensate(ctx context.Context, eid string, completed []Step, opts []ExecuteOption) {
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+
+	for idx := len(completed) - 1; idx >= 0; idx-- {
+		step := completed[idx]
+		cid := step.Options().Compensate
+		if cid == "" {
+			continue
+		}
+
+		w.RLock()
+		cstep, ok := w.steps[cid]
+		w.RUnlock()
+		if !ok {
+			w.opts.Logger.Errorf(ctx, "step %s compensation error: %v", step.ID(), ErrStepNotExists)
+			continue
+		}
+
+		rsp := &Message{}
+		if err := stepStore.Read(ctx, step.ID()+sep+"rsp", rsp); err != nil {
+			w.opts.Logger.Errorf(ctx, "step %s compensation error: %v", step.ID(), err)
+			continue
+		}
+
+		if _, err := w.executeStep(ctx, eid, cstep, rsp, opts); err != nil {
+			w.opts.Logger.Errorf(ctx, "step %s compensation error: %v", step.ID(), err)
+			continue
+		}
+
+		if err := w.writeStepStatus(ctx, eid, step, StatusCompensated, nil, nil); err != nil {
+			w.opts.Logger.Errorf(ctx, "store write error: %v", err)
+		}
+	}
+}
+
+// executeStep runs step with its retry policy and writes its request, response and status to the store
+func (w *microWorkflow) executeStep(ctx context.Context, eid string, step Step, req *Message, opts []ExecuteOption) (*Message, error) {
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+
+	req, err := withIdempotencyKey(step, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if werr := stepStore.Write(ctx, step.ID()+sep+"req", req); werr != nil {
+		return nil, werr
+	}
+	if werr := w.writeStepStatus(ctx, eid, step, StatusRunning, req, nil); werr != nil {
+		return nil, werr
+	}
+
+	rsp, serr := w.executeAttempts(ctx, eid, step, req, opts)
+	if serr != nil {
+		status := StatusFailure
+		// step cancelled because other step failed or execution aborted
+		if ctx.Err() != nil {
+			status = StatusAborted
 		}
+		if werr := stepStore.Write(ctx, step.ID()+sep+"rsp", serr); werr != nil && w.opts.Logger.V(logger.ErrorLevel) {
+			w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
+		}
+		if werr := w.writeStepStatus(ctx, eid, step, status, nil, serr); werr != nil && w.opts.Logger.V(logger.ErrorLevel) {
+			w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
+		}
+		return nil, serr
+	}
+
+	if werr := stepStore.Write(ctx, step.ID()+sep+"rsp", rsp); werr != nil {
+		w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
+		return nil, werr
+	}
+	if werr := w.writeStepStatus(ctx, eid, step, StatusSuccess, rsp, nil); werr != nil {
+		w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
+		return nil, werr
 	}
 
-	return eid, err
+	return rsp, nil
 }
 
 // NewFlow create new flow
@@ -381,7 +784,26 @@ func (f *microFlow) Init(opts ...Option) error {
 }
 
 func (f *microFlow) WorkflowList(ctx context.Context) ([]Workflow, error) {
-	return nil, nil
+	if f.opts.Store == nil {
+		return nil, ErrMissingStore
+	}
+
+	ids, err := store.NewNamespaceStore(f.opts.Store, "definitions").List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	workflows := make([]Workflow, 0, len(ids))
+	for _, id := range ids {
+		w, err := f.WorkflowLoad(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, w)
+	}
+
+	return workflows, nil
 }
 
 func (f *microFlow) WorkflowCreate(ctx context.Context, id string, steps ...Step) (Workflow, error) {
@@ -389,10 +811,20 @@ func (f *microFlow) WorkflowCreate(ctx context.Context, id string, steps ...Step
 
 	for _, s := range steps {
 		w.steps[s.String()] = s
-		w.g.Add(s)
+	}
+
+	// fallback and compensation steps executed only on demand, so they not part of dag
+	handlers := handlerSteps(w.steps)
+	for _, s := range steps {
+		if _, ok := handlers[s.String()]; !ok {
+			w.g.Add(s)
+		}
 	}
 
 	for _, dst := range steps {
+		if _, ok := handlers[dst.String()]; ok {
+			continue
+		}
 		for _, req := range dst.Requires() {
 			src, ok := w.steps[req]
 			if !ok {
@@ -413,20 +845,102 @@ func (f *microFlow) WorkflowCreate(ctx context.Context, id string, steps ...Step
 }
 
 func (f *microFlow) WorkflowRemove(ctx context.Context, id string) error {
-	return nil
+	if f.opts.Store == nil {
+		return ErrMissingStore
+	}
+	return store.NewNamespaceStore(f.opts.Store, "definitions").Delete(ctx, id)
 }
 
 func (f *microFlow) WorkflowSave(ctx context.Context, w Workflow) error {
-	return nil
+	if f.opts.Store == nil {
+		return ErrMissingStore
+	}
+
+	def, err := ExportWorkflow(w)
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+
+	return store.NewNamespaceStore(f.opts.Store, "definitions").Write(ctx, w.ID(), &codec.Frame{Data: buf})
 }
 
 func (f *microFlow) WorkflowLoad(ctx context.Context, id string) (Workflow, error) {
-	return nil, nil
+	if f.opts.Store == nil {
+		return nil, ErrMissingStore
+	}
+
+	buf := &codec.Frame{}
+	if err := store.NewNamespaceStore(f.opts.Store, "definitions").Read(ctx, id, buf); err == store.ErrNotFound {
+		return nil, ErrWorkflowNotExists
+	} else if err != nil {
+		return nil, err
+	}
+
+	def := &WorkflowDefinition{}
+	if err := json.Unmarshal(buf.Data, def); err != nil {
+		return nil, err
+	}
+
+	steps, err := def.NewSteps()
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := f.WorkflowCreate(ctx, id, steps...)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := f.latestStatus(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	w.(*microWorkflow).setStatus(status)
+
+	return w, nil
+}
+
+// latestStatus returns status of the latest workflow execution
+func (f *microFlow) latestStatus(ctx context.Context, id string) (Status, error) {
+	sep := f.opts.Store.Options().Separator
+	executionStore := store.NewNamespaceStore(f.opts.Store, "executions")
+
+	keys, err := executionStore.List(ctx, store.ListPrefix(id+sep))
+	if err != nil {
+		return StatusPending, err
+	}
+
+	var latest, eid string
+	for _, key := range keys {
+		buf := &codec.Frame{}
+		if err = executionStore.Read(ctx, key, buf); err != nil {
+			return StatusPending, err
+		}
+		// timestamps in RFC3339 UTC can be compared as strings
+		if ts := string(buf.Data); ts > latest {
+			latest = ts
+			eid = strings.TrimPrefix(key, id+sep)
+		}
+	}
+
+	if eid == "" {
+		return StatusPending, nil
+	}
+
+	buf := &codec.Frame{}
+	if err = store.NewNamespaceStore(f.opts.Store, "workflows"+sep+eid).Read(ctx, "status", buf); err != nil {
+		return StatusPending, err
+	}
+
+	return StringStatus[string(buf.Data)], nil
 }
 
 type microCallStep struct {
-	rsp     *Message
-	req     *Message
 	service string
 	method  string
 	opts    StepOptions
@@ -434,11 +948,11 @@ type microCallStep struct {
 }
 
 func (s *microCallStep) Request() *Message {
-	return s.req
+	return nil
 }
 
 func (s *microCallStep) Response() *Message {
-	return s.rsp
+	return nil
 }
 
 func (s *microCallStep) ID() string {
@@ -487,11 +1001,32 @@ func (s *microCallStep) SetStatus(status Status) {
 	s.status = status
 }
 
+func (s *microCallStep) Type() string {
+	return "call"
+}
+
+func (s *microCallStep) MarshalStep() (*StepDefinition, error) {
+	def := newStepDefinition(s.Type(), s.ID(), s.opts)
+	def.Service = s.service
+	def.Endpoint = s.method
+	return def, nil
+}
+
+func (s *microCallStep) UnmarshalStep(def *StepDefinition) (Step, error) {
+	opts, err := def.stepOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &microCallStep{service: def.Service, method: def.Endpoint, opts: NewStepOptions(opts...)}, nil
+}
+
 func (s *microCallStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
 	options := NewExecuteOptions(opts...)
 	if options.Client == nil {
 		return nil, ErrMissingClient
 	}
+	ctx, cancel := stepContext(ctx, s.opts, &options)
+	defer cancel()
 	rsp := &codec.Frame{}
 	copts := []client.CallOption{client.WithRetries(0)}
 	if options.Timeout > 0 {
@@ -509,19 +1044,17 @@ func (s *microCallStep) Execute(ctx context.Context, req *Message, opts ...Execu
 }
 
 type microPublishStep struct {
-	req    *Message
-	rsp    *Message
 	topic  string
 	opts   StepOptions
 	status Status
 }
 
 func (s *microPublishStep) Request() *Message {
-	return s.req
+	return nil
 }
 
 func (s *microPublishStep) Response() *Message {
-	return s.rsp
+	return nil
 }
 
 func (s *microPublishStep) ID() string {
@@ -570,8 +1103,37 @@ func (s *microPublishStep) SetStatus(status Status) {
 	s.status = status
 }
 
+func (s *microPublishStep) Type() string {
+	return "publish"
+}
+
+func (s *microPublishStep) MarshalStep() (*StepDefinition, error) {
+	def := newStepDefinition(s.Type(), s.ID(), s.opts)
+	def.Endpoint = s.topic
+	return def, nil
+}
+
+func (s *microPublishStep) UnmarshalStep(def *StepDefinition) (Step, error) {
+	opts, err := def.stepOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &microPublishStep{topic: def.Endpoint, opts: NewStepOptions(opts...)}, nil
+}
+
 func (s *microPublishStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
-	return nil, nil
+	options := NewExecuteOptions(opts...)
+	if options.Client == nil {
+		return nil, ErrMissingClient
+	}
+	ctx, cancel := stepContext(ctx, s.opts, &options)
+	defer cancel()
+	nctx := metadata.NewOutgoingContext(ctx, req.Header)
+	if err := options.Client.Publish(nctx, options.Client.NewMessage(s.topic, &codec.Frame{Data: req.Body})); err != nil {
+		return nil, err
+	}
+	// publish has no response, so pass request to dependent steps
+	return req, nil
 }
 
 // NewCallStep create new step with client.Call
@@ -580,7 +1142,7 @@ func NewCallStep(service string, name string, method string, opts ...StepOption)
 	return &microCallStep{service: service, method: name + "." + method, opts: options}
 }
 
-// NewPublishStep create new step with client.Publish
+// NewPublishStep create new step with client.Publish, step request used as message
 func NewPublishStep(topic string, opts ...StepOption) Step {
 	options := NewStepOptions(opts...)
 	return &microPublishStep{topic: topic, opts: options}
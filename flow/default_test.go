@@ -0,0 +1,709 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/client"
+	"go.unistack.org/micro/v3/codec"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/sync"
+)
+
+func TestWorkflowSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewStore()
+
+	f := NewFlow(Store(s))
+
+	s1 := NewCallStep("service", "Handler", "First", StepID("first"))
+	s2 := NewCallStep("service", "Handler", "Second", StepID("second"), StepRequires("first"), StepFallback("fallback"))
+	s3 := NewPublishStep("topic", StepID("third"), StepRequires("second"), StepCompensate("compensate"))
+	s4 := NewCallStep("service", "Handler", "Fallback", StepID("fallback"))
+	s5 := NewCallStep("service", "Handler", "Compensate", StepID("compensate"))
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2, s3, s4, s5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.WorkflowSave(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+
+	// load workflow by other flow that shares the same store
+	nw, err := NewFlow(Store(s)).WorkflowLoad(ctx, "workflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nw.ID() != "workflow" {
+		t.Fatalf("invalid workflow id %s", nw.ID())
+	}
+
+	if nw.Status() != StatusPending {
+		t.Fatalf("invalid workflow status %s", nw.Status())
+	}
+
+	odef, err := NewWorkflowDefinition(w.ID(), s1, s2, s3, s4, s5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []Step
+	for _, step := range nw.(*microWorkflow).steps {
+		steps = append(steps, step)
+	}
+	ndef, err := NewWorkflowDefinition(nw.ID(), steps...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(odef, ndef) {
+		t.Fatalf("definitions not equal %#+v != %#+v", odef, ndef)
+	}
+
+	workflows, err := f.WorkflowList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workflows) != 1 || workflows[0].ID() != "workflow" {
+		t.Fatalf("invalid workflows %v", workflows)
+	}
+
+	if err = f.(WorkflowRemover).WorkflowRemove(ctx, "workflow"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.WorkflowLoad(ctx, "workflow"); err != ErrWorkflowNotExists {
+		t.Fatalf("expected ErrWorkflowNotExists, got %v", err)
+	}
+}
+
+func TestWorkflowSaveMissingStore(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow()
+
+	w, err := f.WorkflowCreate(ctx, "workflow", NewPublishStep("topic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.WorkflowSave(ctx, w); err != ErrMissingStore {
+		t.Fatalf("expected ErrMissingStore, got %v", err)
+	}
+}
+
+type testStep struct {
+	err     error
+	fn      func(context.Context) error
+	req     *Message
+	id      string
+	body    string
+	count   int
+	timeout time.Duration
+	microCallStep
+}
+
+func (s *testStep) ID() string {
+	return s.id
+}
+
+func (s *testStep) String() string {
+	return s.id
+}
+
+func (s *testStep) Hashcode() interface{} {
+	return s.id
+}
+
+func (s *testStep) Request() *Message {
+	return s.req
+}
+
+// stepStatus returns status of the execution step from the store
+func stepStatus(t *testing.T, f Flow, eid string, id string) Status {
+	sep := f.Options().Store.Options().Separator
+	buf := &codec.Frame{}
+	if err := store.NewNamespaceStore(f.Options().Store, "steps"+sep+eid).Read(context.Background(), id+sep+"status", buf); err != nil {
+		t.Fatal(err)
+	}
+	return StringStatus[string(buf.Data)]
+}
+
+func (s *testStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
+	s.count++
+	s.req = req
+	s.timeout = NewExecuteOptions(opts...).Timeout
+	if s.fn != nil {
+		if err := s.fn(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if s.err != nil {
+		err := s.err
+		s.err = nil
+		return nil, err
+	}
+	if s.body != "" {
+		return &Message{Body: RawMessage(s.body)}, nil
+	}
+	return req, nil
+}
+
+func TestWorkflowResume(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "first"}
+	s2 := &testStep{id: "second", err: errors.New("step error")}
+	s2.opts.Requires = []string{"first"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+	if err == nil {
+		t.Fatal("execute must fail")
+	}
+	if w.Status() != StatusFailure {
+		t.Fatalf("invalid status %s", w.Status())
+	}
+
+	if err = w.Resume(ctx, eid); err != nil {
+		t.Fatal(err)
+	}
+	if w.Status() != StatusSuccess {
+		t.Fatalf("invalid status %s", w.Status())
+	}
+
+	if s1.count != 1 || s2.count != 2 {
+		t.Fatalf("invalid executions count first %d second %d", s1.count, s2.count)
+	}
+
+	if err = w.Resume(ctx, "unknown"); err != ErrExecutionNotExists {
+		t.Fatalf("expected ErrExecutionNotExists, got %v", err)
+	}
+}
+
+func TestWorkflowResumeLocked(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "first", err: errors.New("step error")}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+	if err == nil {
+		t.Fatal("execute must fail")
+	}
+
+	mw := w.(*microWorkflow)
+	if err = mw.writeLease(ctx, eid, "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Resume(ctx, eid); err != ErrExecutionLocked {
+		t.Fatalf("expected ErrExecutionLocked, got %v", err)
+	}
+
+	if err = mw.releaseLease(ctx, eid, "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Resume(ctx, eid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkflowSuspendResume(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	var count int32
+	started := make(chan struct{})
+	done := make(chan struct{})
+	s1 := &testStep{id: "first", fn: func(context.Context) error {
+		if atomic.AddInt32(&count, 1) == 1 {
+			close(started)
+			<-done
+		}
+		return nil
+	}}
+	s2 := &testStep{id: "second"}
+	s2.opts.Requires = []string{"first"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{}`)}, ExecuteAsync(true), ExecuteTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	if err = w.Suspend(ctx, eid); err != nil {
+		t.Fatal(err)
+	}
+	// running worker holds lease until its steps finished
+	if err = w.Resume(ctx, eid); err != ErrExecutionLocked {
+		t.Fatalf("expected ErrExecutionLocked, got %v", err)
+	}
+	close(done)
+
+	for ts := time.Now(); ; time.Sleep(time.Millisecond) {
+		if err = w.Resume(ctx, eid); err != ErrExecutionLocked {
+			break
+		}
+		if time.Since(ts) > time.Second {
+			t.Fatal("lease must be released after running steps finished")
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&count); n != 1 || s2.count != 1 {
+		t.Fatalf("steps must be executed once, first %d second %d", n, s2.count)
+	}
+	if s2.timeout != time.Minute {
+		t.Fatalf("resumed execution must restore execute timeout, got %s", s2.timeout)
+	}
+}
+
+func TestWorkflowLeaseSync(t *testing.T) {
+	ctx := context.Background()
+	sy := sync.NewSync()
+	f := NewFlow(Store(store.NewStore()), Sync(sy))
+
+	w, err := f.WorkflowCreate(ctx, "workflow", NewDelayStep(time.Millisecond, StepID("first")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw := w.(*microWorkflow)
+
+	if err = sy.Lock("flow/lease/eid"); err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- mw.acquireLease(ctx, "eid", "first")
+	}()
+
+	select {
+	case err = <-errCh:
+		t.Fatalf("acquire must wait for lease lock, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err = sy.Unlock("flow/lease/eid"); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if err = mw.acquireLease(ctx, "eid", "second"); err != ErrExecutionLocked {
+		t.Fatalf("expected ErrExecutionLocked, got %v", err)
+	}
+}
+
+func TestWorkflowLeaseDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	for _, ttl := range []time.Duration{0, time.Nanosecond} {
+		f := NewFlow(Store(store.NewStore()), LeaseTTL(ttl))
+		w, err := f.WorkflowCreate(ctx, "workflow", NewDelayStep(time.Millisecond, StepID("first")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}); err != nil {
+			t.Fatalf("lease ttl %s: %v", ttl, err)
+		}
+	}
+}
+
+func TestWorkflowStepRequest(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "first", body: `{"id":1}`}
+	s2 := &testStep{id: "second", body: `{"id":2}`}
+	s2.opts.Requires = []string{"first"}
+	s3 := &testStep{id: "third"}
+	s3.opts.Requires = []string{"first", "second"}
+	s4 := &testStep{id: "fourth"}
+	s4.opts.Requires = []string{"third"}
+	s4.opts.Input = map[string]string{"user.id": "$.third.id", "name": "$.request.name", "kind": "const"}
+	s3.body = `{"id":3}`
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2, s3, s4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{"name":"test"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := string(s1.Request().Body); v != `{"name":"test"}` {
+		t.Fatalf("invalid first request %s", v)
+	}
+	if v := string(s2.Request().Body); v != `{"id":1}` {
+		t.Fatalf("invalid second request %s", v)
+	}
+	if v := string(s3.Request().Body); v != `{"first":{"id":1},"second":{"id":2}}` {
+		t.Fatalf("invalid third request %s", v)
+	}
+	if v := string(s4.Request().Body); v != `{"kind":"const","name":"test","user":{"id":3}}` {
+		t.Fatalf("invalid fourth request %s", v)
+	}
+}
+
+func TestWorkflowStepTimeout(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "short"}
+	s1.opts = NewStepOptions(StepTimeout(time.Second))
+	s2 := &testStep{id: "long"}
+	s2.opts = NewStepOptions(StepRequires("short"), StepTimeout(time.Hour))
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}, ExecuteTimeout(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s1.timeout != time.Second {
+		t.Fatalf("step timeout must be used, got %v", s1.timeout)
+	}
+	if s2.timeout != time.Minute {
+		t.Fatalf("execute timeout must be used, got %v", s2.timeout)
+	}
+}
+
+func TestWorkflowStepRequestDottedID(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "svc.Handler", body: `{"id":0}`}
+	s2 := &testStep{id: "svc.Handler.Method", body: `{"id":1,"user":{"name":"test"}}`}
+	s2.opts.Requires = []string{"svc.Handler"}
+	s3 := &testStep{id: "last"}
+	s3.opts.Requires = []string{"svc.Handler", "svc.Handler.Method"}
+	s3.opts.Input = map[string]string{"id": "$.svc.Handler.Method.id", "name": "$.svc.Handler.Method.user.name", "prev": "$.svc.Handler.id"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2, s3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := string(s3.Request().Body); v != `{"id":1,"name":"test","prev":0}` {
+		t.Fatalf("invalid last request %s", v)
+	}
+}
+
+func TestWorkflowParallel(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	var running, maxRunning int32
+	fn := func(ctx context.Context) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+
+	root := &testStep{id: "root"}
+	steps := []Step{root}
+	for _, id := range []string{"a", "b", "c"} {
+		step := &testStep{id: id, fn: fn}
+		step.opts.Requires = []string{"root"}
+		steps = append(steps, step)
+	}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", steps...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt32(&maxRunning); v != 3 {
+		t.Fatalf("expected 3 steps running in parallel, got %d", v)
+	}
+
+	atomic.StoreInt32(&maxRunning, 0)
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}, ExecuteMaxConcurrency(2)); err != nil {
+		t.Fatal(err)
+	}
+	if v := atomic.LoadInt32(&maxRunning); v != 2 {
+		t.Fatalf("expected 2 steps running in parallel, got %d", v)
+	}
+}
+
+func TestWorkflowFailFast(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	root := &testStep{id: "root"}
+	s1 := &testStep{id: "fail", fn: func(context.Context) error { return errors.New("step error") }}
+	s1.opts.Requires = []string{"root"}
+	s2 := &testStep{id: "wait", fn: func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}}
+	s2.opts.Requires = []string{"root"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", root, s1, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+	if err == nil || err.Error() != "step error" {
+		t.Fatalf("expected step error, got %v", err)
+	}
+	if status := stepStatus(t, f, eid, "wait"); status != StatusAborted {
+		t.Fatalf("sibling step must be aborted, got %s", status)
+	}
+}
+
+func TestWorkflowContinueOnError(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	root := &testStep{id: "root"}
+	s1 := &testStep{id: "fail", err: errors.New("step error")}
+	s1.opts.Requires = []string{"root"}
+	s2 := &testStep{id: "ok"}
+	s2.opts.Requires = []string{"root"}
+	s3 := &testStep{id: "after_fail"}
+	s3.opts.Requires = []string{"fail"}
+	s4 := &testStep{id: "after_ok"}
+	s4.opts.Requires = []string{"ok"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", root, s1, s2, s3, s4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}, ExecuteContinueOnError(true)); err == nil {
+		t.Fatal("execute must fail")
+	}
+	if s3.count != 0 || s4.count != 1 {
+		t.Fatalf("invalid executions count after_fail %d after_ok %d", s3.count, s4.count)
+	}
+	if w.Status() != StatusFailure {
+		t.Fatalf("invalid status %s", w.Status())
+	}
+}
+
+func TestWorkflowFallback(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "first", err: errors.New("step error")}
+	s1.opts.Fallback = "fallback"
+	s2 := &testStep{id: "second"}
+	s2.opts.Requires = []string{"first"}
+	fb := &testStep{id: "fallback", body: `{"fallback":true}`}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2, fb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if fb.count != 1 {
+		t.Fatalf("fallback must be executed once, got %d", fb.count)
+	}
+	if v := string(s2.Request().Body); v != `{"fallback":true}` {
+		t.Fatalf("invalid second request %s", v)
+	}
+}
+
+func TestWorkflowRemoveSteps(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "first"}
+	s1.opts.Fallback = "fallback"
+	s2 := &testStep{id: "second"}
+	s2.opts.Requires = []string{"first"}
+	fb := &testStep{id: "fallback"}
+	fb.opts.Requires = []string{"first"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2, fb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := &testStep{id: "missing"}
+	missing.opts.Requires = []string{"unknown"}
+	if err = w.RemoveSteps(missing); err != ErrStepNotExists {
+		t.Fatalf("expected ErrStepNotExists, got %v", err)
+	}
+
+	if err = w.RemoveSteps(fb); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if s1.count != 1 || s2.count != 1 || fb.count != 0 {
+		t.Fatalf("invalid executions first %d second %d fallback %d", s1.count, s2.count, fb.count)
+	}
+}
+
+func TestWorkflowCompensate(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewStore()
+	f := NewFlow(Store(s))
+
+	var order []string
+	undo := func(id string) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, id)
+			return nil
+		}
+	}
+
+	s1 := &testStep{id: "first", body: `{"id":1}`}
+	s1.opts.Compensate = "undo_first"
+	s2 := &testStep{id: "second"}
+	s2.opts.Requires = []string{"first"}
+	s2.opts.Compensate = "undo_second"
+	s3 := &testStep{id: "third", err: errors.New("step error")}
+	s3.opts.Requires = []string{"second"}
+	s3.opts.Fallback = "fallback"
+	fb := &testStep{id: "fallback", err: errors.New("fallback error")}
+	u1 := &testStep{id: "undo_first", fn: undo("first")}
+	u2 := &testStep{id: "undo_second", fn: undo("second")}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2, s3, fb, u1, u2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+	if err == nil || err.Error() != "step error" {
+		t.Fatalf("expected step error, got %v", err)
+	}
+
+	if fb.count != 1 {
+		t.Fatalf("fallback must be executed once, got %d", fb.count)
+	}
+	if !reflect.DeepEqual(order, []string{"second", "first"}) {
+		t.Fatalf("invalid compensation order %v", order)
+	}
+	if v := string(u1.Request().Body); v != `{"id":1}` {
+		t.Fatalf("invalid compensation request %s", v)
+	}
+
+	stepStore := store.NewNamespaceStore(s, "steps"+s.Options().Separator+eid)
+	for id, status := range map[string]Status{"first": StatusCompensated, "second": StatusCompensated, "third": StatusFailure} {
+		buf := &codec.Frame{}
+		if err = stepStore.Read(ctx, id+s.Options().Separator+"status", buf); err != nil {
+			t.Fatal(err)
+		}
+		if v := StringStatus[string(buf.Data)]; v != status {
+			t.Fatalf("step %s invalid status %s", id, v)
+		}
+	}
+}
+
+func TestWorkflowStepRetry(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewStore()
+	f := NewFlow(Store(s))
+
+	backoffs := 0
+	backoff := func(context.Context, client.Request, int) (time.Duration, error) {
+		backoffs++
+		return time.Millisecond, nil
+	}
+
+	fails := 2
+	s1 := &testStep{id: "retry", fn: func(context.Context) error {
+		if fails > 0 {
+			fails--
+			return errors.New("step error")
+		}
+		return nil
+	}}
+	s1.opts = NewStepOptions(StepRetries(2), StepBackoff(backoff), StepIdempotencyKey("$.id"))
+	s2 := &testStep{id: "timeout", fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	s2.opts = NewStepOptions(StepRequires("retry"), StepTimeout(10*time.Millisecond), StepRetries(1), StepRetry(client.RetryNever))
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{"id":"key"}`)})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if s1.count != 3 || backoffs != 2 {
+		t.Fatalf("invalid attempts %d backoffs %d", s1.count, backoffs)
+	}
+	if v, _ := s1.Request().Header.Get(metadata.HeaderIdempotencyKey); v != "key" {
+		t.Fatalf("invalid idempotency key %s", v)
+	}
+	if s2.count != 1 {
+		t.Fatalf("step must not be retried, got %d attempts", s2.count)
+	}
+
+	sep := s.Options().Separator
+	stepStore := store.NewNamespaceStore(s, "steps"+sep+eid)
+	for id, attempts := range map[string]string{"retry": "3", "timeout": "1"} {
+		buf := &codec.Frame{}
+		if err = stepStore.Read(ctx, id+sep+"attempts", buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf.Data) != attempts {
+			t.Fatalf("step %s invalid attempts %s", id, buf.Data)
+		}
+	}
+}
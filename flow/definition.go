@@ -0,0 +1,152 @@
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StepDefinition holds serializable step definition
+type StepDefinition struct {
+	// Type of the step used to find registered step
+	Type string `json:"type" yaml:"type"`
+	// ID of the step
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Service name for call steps
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+	// Endpoint is rpc endpoint, broker topic or workflow id
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// Duration for delay steps
+	Duration string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// Path is util/reflect.Lookup path used by wait and branch steps
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Branches contains branch step cases
+	Branches map[string]string `json:"branches,omitempty" yaml:"branches,omitempty"`
+	// Fallback step id
+	Fallback string `json:"fallback,omitempty" yaml:"fallback,omitempty"`
+	// Compensate step id
+	Compensate string `json:"compensate,omitempty" yaml:"compensate,omitempty"`
+	// Input contains request mapping
+	Input map[string]string `json:"input,omitempty" yaml:"input,omitempty"`
+	// IdempotencyKey passed in request header
+	IdempotencyKey string `json:"idempotency_key,omitempty" yaml:"idempotency_key,omitempty"`
+	// Timeout for each step attempt
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Retries contains number of retries
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+	// Requires contains required step ids
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+}
+
+// WorkflowDefinition holds serializable workflow definition
+type WorkflowDefinition struct {
+	// ID of the workflow
+	ID string `json:"id" yaml:"id"`
+	// Steps of the workflow
+	Steps []*StepDefinition `json:"steps" yaml:"steps"`
+}
+
+// StepMarshaler is implemented by steps that can be saved by WorkflowSave.
+// To be loaded by WorkflowLoad step must be registered via RegisterStep.
+type StepMarshaler interface {
+	// Type returns the step type name
+	Type() string
+	// MarshalStep returns step definition
+	MarshalStep() (*StepDefinition, error)
+	// UnmarshalStep returns new step created from definition
+	UnmarshalStep(*StepDefinition) (Step, error)
+}
+
+// NewStepFromDefinition creates step from definition using registered steps
+func NewStepFromDefinition(def *StepDefinition) (Step, error) {
+	steps, _ := atomicSteps.Load().([]Step)
+	for _, step := range steps {
+		if m, ok := step.(StepMarshaler); ok && m.Type() == def.Type {
+			return m.UnmarshalStep(def)
+		}
+	}
+	return nil, fmt.Errorf("step type %q not registered", def.Type)
+}
+
+// NewWorkflowDefinition returns definition for workflow id with steps
+func NewWorkflowDefinition(id string, steps ...Step) (*WorkflowDefinition, error) {
+	def := &WorkflowDefinition{ID: id, Steps: make([]*StepDefinition, 0, len(steps))}
+
+	for _, step := range steps {
+		m, ok := step.(StepMarshaler)
+		if !ok {
+			return nil, fmt.Errorf("step %s does not implement StepMarshaler", step.ID())
+		}
+		sdef, err := m.MarshalStep()
+		if err != nil {
+			return nil, err
+		}
+		def.Steps = append(def.Steps, sdef)
+	}
+
+	sort.Slice(def.Steps, func(i, j int) bool { return def.Steps[i].ID < def.Steps[j].ID })
+
+	return def, nil
+}
+
+// NewSteps creates workflow steps from definition
+func (def *WorkflowDefinition) NewSteps() ([]Step, error) {
+	steps := make([]Step, 0, len(def.Steps))
+	for _, sdef := range def.Steps {
+		step, err := NewStepFromDefinition(sdef)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// stepOptions returns step options from definition, invalid timeout returns error
+func (def *StepDefinition) stepOptions() ([]StepOption, error) {
+	opts := []StepOption{StepID(def.ID)}
+	if len(def.Requires) > 0 {
+		opts = append(opts, StepRequires(def.Requires...))
+	}
+	if len(def.Fallback) > 0 {
+		opts = append(opts, StepFallback(def.Fallback))
+	}
+	if len(def.Compensate) > 0 {
+		opts = append(opts, StepCompensate(def.Compensate))
+	}
+	if len(def.Input) > 0 {
+		opts = append(opts, StepInput(def.Input))
+	}
+	if len(def.IdempotencyKey) > 0 {
+		opts = append(opts, StepIdempotencyKey(def.IdempotencyKey))
+	}
+	if def.Retries > 0 {
+		opts = append(opts, StepRetries(def.Retries))
+	}
+	if def.Timeout != "" {
+		td, err := time.ParseDuration(def.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("step %s invalid timeout: %w", def.ID, err)
+		}
+		opts = append(opts, StepTimeout(td))
+	}
+	return opts, nil
+}
+
+// newStepDefinition returns definition with fields from step options
+func newStepDefinition(typ string, id string, opts StepOptions) *StepDefinition {
+	def := &StepDefinition{
+		Type:           typ,
+		ID:             id,
+		Fallback:       opts.Fallback,
+		Compensate:     opts.Compensate,
+		Input:          opts.Input,
+		Requires:       opts.Requires,
+		Retries:        opts.Retries,
+		IdempotencyKey: opts.IdempotencyKey,
+	}
+	if opts.Timeout > 0 {
+		def.Timeout = opts.Timeout.String()
+	}
+	return def
+}
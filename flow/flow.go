@@ -7,6 +7,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"go.unistack.org/micro/v3/broker"
 	"go.unistack.org/micro/v3/metadata"
 )
 
@@ -15,6 +16,16 @@ var (
 	ErrStepNotExists = errors.New("step not exists")
 	// ErrMissingClient returns when client.Client is missing
 	ErrMissingClient = errors.New("client not set")
+	// ErrMissingStore returns when store.Store is missing
+	ErrMissingStore = errors.New("store not set")
+	// ErrWorkflowNotExists returns when workflow not found
+	ErrWorkflowNotExists = errors.New("workflow not exists")
+	// ErrExecutionNotExists returns when workflow execution not found
+	ErrExecutionNotExists = errors.New("execution not exists")
+	// ErrExecutionLocked returns when execution lease held by other worker
+	ErrExecutionLocked = errors.New("execution locked")
+	// ErrMissingEventTopic returns when events topic is not set
+	ErrMissingEventTopic = errors.New("event topic not set")
 )
 
 // RawMessage is a raw encoded JSON value.
@@ -90,26 +101,34 @@ const (
 	StatusAborted
 	// StatusSuspend step suspended
 	StatusSuspend
+	// StatusCompensated step completed but its effect undone by compensation step
+	StatusCompensated
+	// StatusSkipped step not executed because branch step selected other step
+	StatusSkipped
 )
 
 var (
 	// StatusString contains map status => string
 	StatusString = map[Status]string{
-		StatusPending: "StatusPending",
-		StatusRunning: "StatusRunning",
-		StatusFailure: "StatusFailure",
-		StatusSuccess: "StatusSuccess",
-		StatusAborted: "StatusAborted",
-		StatusSuspend: "StatusSuspend",
+		StatusPending:     "StatusPending",
+		StatusRunning:     "StatusRunning",
+		StatusFailure:     "StatusFailure",
+		StatusSuccess:     "StatusSuccess",
+		StatusAborted:     "StatusAborted",
+		StatusSuspend:     "StatusSuspend",
+		StatusCompensated: "StatusCompensated",
+		StatusSkipped:     "StatusSkipped",
 	}
 	// StringStatus contains map string => status
 	StringStatus = map[string]Status{
-		"StatusPending": StatusPending,
-		"StatusRunning": StatusRunning,
-		"StatusFailure": StatusFailure,
-		"StatusSuccess": StatusSuccess,
-		"StatusAborted": StatusAborted,
-		"StatusSuspend": StatusSuspend,
+		"StatusPending":     StatusPending,
+		"StatusRunning":     StatusRunning,
+		"StatusFailure":     StatusFailure,
+		"StatusSuccess":     StatusSuccess,
+		"StatusAborted":     StatusAborted,
+		"StatusSuspend":     StatusSuspend,
+		"StatusCompensated": StatusCompensated,
+		"StatusSkipped":     StatusSkipped,
 	}
 )
 
@@ -129,7 +148,7 @@ type Workflow interface {
 	Steps() ([][]Step, error)
 	// Suspend suspends execution
 	Suspend(ctx context.Context, id string) error
-	// Resume resumes execution
+	// Resume continues suspended or interrupted execution, steps already succeeded are skipped
 	Resume(ctx context.Context, id string) error
 	// Abort abort execution
 	Abort(ctx context.Context, id string) error
@@ -151,6 +170,22 @@ type Flow interface {
 	WorkflowList(ctx context.Context) ([]Workflow, error)
 }
 
+// WorkflowRemover is implemented by flows that can remove stored workflows, check it by type assertion on Flow
+type WorkflowRemover interface {
+	// WorkflowRemove removes workflow with specific id
+	WorkflowRemove(ctx context.Context, id string) error
+}
+
+// ExecutionHistory is implemented by flows that keep executions history, check it by type assertion on Flow
+type ExecutionHistory interface {
+	// ExecutionList lists workflow executions filtered by options
+	ExecutionList(ctx context.Context, opts ...ListOption) ([]*Execution, error)
+	// ExecutionTimeline returns execution and its steps status changes ordered by time
+	ExecutionTimeline(ctx context.Context, id string) ([]*Event, error)
+	// EventSubscribe subscribes to status change events published to the EventTopic
+	EventSubscribe(ctx context.Context, fn func(*Event) error) (broker.Subscriber, error)
+}
+
 var (
 	flowMu      sync.Mutex
 	atomicSteps atomic.Value
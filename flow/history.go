@@ -0,0 +1,225 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.unistack.org/micro/v3/broker"
+	"go.unistack.org/micro/v3/codec"
+	"go.unistack.org/micro/v3/store"
+)
+
+// Event describes status change of workflow execution or its step
+type Event struct {
+	// Time of the status change
+	Time time.Time `json:"time"`
+	// Workflow id
+	Workflow string `json:"workflow"`
+	// Execution id
+	Execution string `json:"execution"`
+	// Step id, empty for execution status change
+	Step string `json:"step,omitempty"`
+	// Status after change
+	Status string `json:"status"`
+	// Error contains step or execution error
+	Error string `json:"error,omitempty"`
+	// Payload contains step request for running step and response for succeeded step
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Duration since step or execution started, filled by ExecutionTimeline for finished states
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Execution holds workflow execution info
+type Execution struct {
+	// Created time of the execution
+	Created time.Time `json:"created"`
+	// ID of the execution
+	ID string `json:"id"`
+	// Workflow id
+	Workflow string `json:"workflow"`
+	// Status of the execution
+	Status Status `json:"status"`
+}
+
+// writeStatus writes execution status to the store and records event
+func (w *microWorkflow) writeStatus(ctx context.Context, eid string, status Status, err error) error {
+	workflowStore := store.NewNamespaceStore(w.opts.Store, "workflows"+w.opts.Store.Options().Separator+eid)
+	if werr := workflowStore.Write(ctx, "status", &codec.Frame{Data: []byte(status.String())}); werr != nil {
+		return werr
+	}
+	w.recordEvent(ctx, &Event{Execution: eid, Status: status.String()}, nil, err)
+	return nil
+}
+
+// writeStepStatus writes step status to the store and records event
+func (w *microWorkflow) writeStepStatus(ctx context.Context, eid string, step Step, status Status, payload *Message, err error) error {
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+	if werr := stepStore.Write(ctx, step.ID()+sep+"status", &codec.Frame{Data: []byte(status.String())}); werr != nil {
+		return werr
+	}
+	w.recordEvent(ctx, &Event{Execution: eid, Step: step.ID(), Status: status.String()}, payload, err)
+	return nil
+}
+
+// recordEvent writes event to the execution history and publishes it to the events topic,
+// errors only logged because history must not break execution
+func (w *microWorkflow) recordEvent(ctx context.Context, evt *Event, payload *Message, err error) {
+	sep := w.opts.Store.Options().Separator
+
+	evt.Time = time.Now().UTC()
+	evt.Workflow = w.id
+	if err != nil {
+		evt.Error = err.Error()
+	}
+	if payload != nil && json.Valid(payload.Body) {
+		evt.Payload = json.RawMessage(payload.Body)
+	}
+
+	buf, merr := json.Marshal(evt)
+	if merr != nil {
+		w.opts.Logger.Errorf(ctx, "event marshal error: %v", merr)
+		return
+	}
+
+	historyStore := store.NewNamespaceStore(w.opts.Store, "history"+sep+evt.Execution)
+	key := fmt.Sprintf("%020d%s%s", evt.Time.UnixNano(), sep, evt.Step)
+	if werr := historyStore.Write(ctx, key, &codec.Frame{Data: buf}); werr != nil {
+		w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
+	}
+
+	if w.opts.EventTopic == "" || w.opts.Client == nil {
+		return
+	}
+	if perr := w.opts.Client.Publish(ctx, w.opts.Client.NewMessage(w.opts.EventTopic, &codec.Frame{Data: buf})); perr != nil {
+		w.opts.Logger.Errorf(ctx, "event publish error: %v", perr)
+	}
+}
+
+func (f *microFlow) ExecutionList(ctx context.Context, opts ...ListOption) ([]*Execution, error) {
+	if f.opts.Store == nil {
+		return nil, ErrMissingStore
+	}
+
+	options := NewListOptions(opts...)
+	sep := f.opts.Store.Options().Separator
+	executionStore := store.NewNamespaceStore(f.opts.Store, "executions")
+
+	var lopts []store.ListOption
+	if options.Workflow != "" {
+		lopts = append(lopts, store.ListPrefix(options.Workflow+sep))
+	}
+	keys, err := executionStore.List(ctx, lopts...)
+	if err != nil {
+		return nil, err
+	}
+
+	executions := make([]*Execution, 0, len(keys))
+	for _, key := range keys {
+		idx := strings.LastIndex(key, sep)
+		if idx < 0 {
+			continue
+		}
+		e := &Execution{Workflow: key[:idx], ID: key[idx+len(sep):]}
+
+		buf := &codec.Frame{}
+		if err = executionStore.Read(ctx, key, buf); err != nil {
+			return nil, err
+		}
+		if e.Created, err = time.Parse(time.RFC3339Nano, string(buf.Data)); err != nil {
+			return nil, err
+		}
+		if !options.From.IsZero() && e.Created.Before(options.From) {
+			continue
+		}
+		if !options.To.IsZero() && !e.Created.Before(options.To) {
+			continue
+		}
+
+		buf = &codec.Frame{}
+		if err = store.NewNamespaceStore(f.opts.Store, "workflows"+sep+e.ID).Read(ctx, "status", buf); err != nil && err != store.ErrNotFound {
+			return nil, err
+		}
+		e.Status = StringStatus[string(buf.Data)]
+		if len(options.Status) > 0 && !containsStatus(options.Status, e.Status) {
+			continue
+		}
+
+		executions = append(executions, e)
+	}
+
+	sort.Slice(executions, func(i, j int) bool { return executions[i].Created.Before(executions[j].Created) })
+
+	return executions, nil
+}
+
+func containsStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *microFlow) ExecutionTimeline(ctx context.Context, id string) ([]*Event, error) {
+	if f.opts.Store == nil {
+		return nil, ErrMissingStore
+	}
+
+	historyStore := store.NewNamespaceStore(f.opts.Store, "history"+f.opts.Store.Options().Separator+id)
+	keys, err := historyStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrExecutionNotExists
+	}
+	sort.Strings(keys)
+
+	events := make([]*Event, 0, len(keys))
+	started := make(map[string]time.Time)
+	for _, key := range keys {
+		buf := &codec.Frame{}
+		if err = historyStore.Read(ctx, key, buf); err != nil {
+			return nil, err
+		}
+		evt := &Event{}
+		if err = json.Unmarshal(buf.Data, evt); err != nil {
+			return nil, err
+		}
+		switch StringStatus[evt.Status] {
+		case StatusRunning:
+			started[evt.Step] = evt.Time
+		case StatusPending:
+		default:
+			if ts, ok := started[evt.Step]; ok {
+				evt.Duration = evt.Time.Sub(ts)
+			}
+		}
+		events = append(events, evt)
+	}
+
+	return events, nil
+}
+
+func (f *microFlow) EventSubscribe(ctx context.Context, fn func(*Event) error) (broker.Subscriber, error) {
+	if f.opts.EventTopic == "" {
+		return nil, ErrMissingEventTopic
+	}
+	if f.opts.Client == nil {
+		return nil, ErrMissingClient
+	}
+
+	return f.opts.Client.Options().Broker.Subscribe(ctx, f.opts.EventTopic, func(msg broker.Event) error {
+		evt := &Event{}
+		if err := json.Unmarshal(msg.Message().Body, evt); err != nil {
+			return err
+		}
+		return fn(evt)
+	})
+}
@@ -0,0 +1,164 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/store"
+)
+
+func TestExecutionList(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	w1, err := f.WorkflowCreate(ctx, "first", &testStep{id: "step"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2, err := f.WorkflowCreate(ctx, "second", &testStep{id: "step", err: errors.New("step error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid1, err := w1.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eid2, _ := w2.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+
+	executions, err := f.(ExecutionHistory).ExecutionList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 2 || executions[0].ID != eid1 || executions[1].ID != eid2 {
+		t.Fatalf("invalid executions %v", executions)
+	}
+
+	executions, err = f.(ExecutionHistory).ExecutionList(ctx, ListWorkflow("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 1 || executions[0].ID != eid2 || executions[0].Status != StatusFailure {
+		t.Fatalf("invalid executions %v", executions)
+	}
+
+	executions, err = f.(ExecutionHistory).ExecutionList(ctx, ListStatus(StatusSuccess))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 1 || executions[0].ID != eid1 || executions[0].Workflow != "first" {
+		t.Fatalf("invalid executions %v", executions)
+	}
+
+	executions, err = f.(ExecutionHistory).ExecutionList(ctx, ListTimeRange(time.Now().Add(time.Minute), time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 0 {
+		t.Fatalf("invalid executions %v", executions)
+	}
+}
+
+func TestExecutionTimeline(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	s1 := &testStep{id: "first", body: `{"id":1}`}
+	s2 := &testStep{id: "second", err: errors.New("step error")}
+	s2.opts.Requires = []string{"first"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", s1, s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, _ := w.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+
+	if _, err = f.(ExecutionHistory).ExecutionTimeline(ctx, "missing"); err != ErrExecutionNotExists {
+		t.Fatalf("expected ErrExecutionNotExists, got %v", err)
+	}
+
+	events, err := f.(ExecutionHistory).ExecutionTimeline(ctx, eid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for idx, evt := range events {
+		if idx > 0 && evt.Time.Before(events[idx-1].Time) {
+			t.Fatalf("events not ordered %v", events)
+		}
+		if evt.Workflow != "workflow" || evt.Execution != eid {
+			t.Fatalf("invalid event %#v", evt)
+		}
+		if evt.Status == StatusSuccess.String() || evt.Status == StatusFailure.String() {
+			got = append(got, evt.Step+":"+evt.Status)
+		}
+		switch {
+		case evt.Step == "first" && evt.Status == StatusSuccess.String():
+			if string(evt.Payload) != `{"id":1}` {
+				t.Fatalf("invalid payload %s", evt.Payload)
+			}
+		case evt.Step == "second" && evt.Status == StatusFailure.String():
+			if evt.Error != "step error" {
+				t.Fatalf("invalid error %s", evt.Error)
+			}
+		}
+	}
+
+	expected := []string{"first:" + StatusSuccess.String(), "second:" + StatusFailure.String(), ":" + StatusFailure.String()}
+	if len(got) != len(expected) {
+		t.Fatalf("invalid timeline %v", got)
+	}
+	for idx := range expected {
+		if got[idx] != expected[idx] {
+			t.Fatalf("invalid timeline %v", got)
+		}
+	}
+}
+
+func TestEventSubscribe(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	if _, err := NewFlow(Store(store.NewStore())).(ExecutionHistory).EventSubscribe(ctx, nil); err != ErrMissingEventTopic {
+		t.Fatalf("expected ErrMissingEventTopic, got %v", err)
+	}
+
+	f := NewFlow(Store(store.NewStore()), Client(c), EventTopic("flow.events"))
+
+	events := make(chan *Event, 16)
+	sub, err := f.(ExecutionHistory).EventSubscribe(ctx, func(evt *Event) error {
+		events <- evt
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe(ctx)
+
+	w, err := f.WorkflowCreate(ctx, "workflow", &testStep{id: "step"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		select {
+		case evt := <-events:
+			if evt.Execution != eid {
+				t.Fatalf("invalid event %#v", evt)
+			}
+			if evt.Step == "" && evt.Status == StatusSuccess.String() {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("execution success event not received")
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/store"
+	rutil "go.unistack.org/micro/v3/util/reflect"
+)
+
+// InputRequest is the key of the workflow request in step input mapping source
+const InputRequest = "request"
+
+// stepRequest returns request for the step built from responses of required steps.
+// Step without required steps gets workflow request, step with single required step gets its response,
+// step with multiple required steps gets JSON object with responses keyed by step id.
+// If step has input mapping, request body built by it from workflow request and required steps responses.
+func (w *microWorkflow) stepRequest(ctx context.Context, stepStore store.Store, step Step, req *Message) (*Message, error) {
+	sep := w.opts.Store.Options().Separator
+
+	ids := make([]string, 0, len(step.Requires()))
+	parents := make([]*Message, 0, len(step.Requires()))
+	for _, id := range step.Requires() {
+		rsp := &Message{}
+		if err := stepStore.Read(ctx, id+sep+"rsp", rsp); err == store.ErrNotFound {
+			// required step not executed, for example when execution started from the middle
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		parents = append(parents, rsp)
+	}
+
+	input := step.Options().Input
+	if len(input) == 0 {
+		switch len(parents) {
+		case 0:
+			return req, nil
+		case 1:
+			return parents[0], nil
+		}
+	}
+
+	msg := &Message{Header: metadata.New(0)}
+	if len(parents) == 0 {
+		msg.Header = metadata.Copy(req.Header)
+	}
+	for _, parent := range parents {
+		msg.Header = metadata.Merge(msg.Header, parent.Header, true)
+	}
+
+	var err error
+	if len(input) == 0 {
+		bodies := make(map[string]json.RawMessage, len(parents))
+		for idx, parent := range parents {
+			bodies[ids[idx]] = json.RawMessage(parent.Body)
+		}
+		msg.Body, err = json.Marshal(bodies)
+		return msg, err
+	}
+
+	src := make(map[string]interface{}, len(parents)+1)
+	if src[InputRequest], err = decodeBody(req.Body); err != nil {
+		return nil, err
+	}
+	for idx, parent := range parents {
+		if src[ids[idx]], err = decodeBody(parent.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	dst := make(map[string]interface{}, len(input))
+	for field, path := range input {
+		var val interface{} = path
+		if strings.HasPrefix(path, "$") {
+			if val, err = lookupInput(src, path); err != nil {
+				return nil, err
+			}
+		}
+		setInputField(dst, field, val)
+	}
+
+	msg.Body, err = json.Marshal(dst)
+	return msg, err
+}
+
+// lookupInput returns value by path from input source, step ids can contain dots,
+// so path resolved against the longest matching step id before lookup in its response
+func lookupInput(src map[string]interface{}, path string) (interface{}, error) {
+	rest := strings.TrimPrefix(path, "$"+rutil.SplitToken)
+	var key string
+	for k := range src {
+		if len(k) > len(key) && (rest == k || strings.HasPrefix(rest, k+rutil.SplitToken)) {
+			key = k
+		}
+	}
+	if key == "" || path == "$" {
+		rval, err := rutil.Lookup(src, path)
+		if err != nil {
+			return nil, err
+		}
+		return rval.Interface(), nil
+	}
+
+	fpath := "$"
+	if rest != key {
+		fpath += rest[len(key):]
+	}
+	rval, err := rutil.Lookup(src[key], fpath)
+	if err != nil {
+		return nil, err
+	}
+	return rval.Interface(), nil
+}
+
+// setInputField sets value in dst by dot separated path, nested objects created on the way
+func setInputField(dst map[string]interface{}, path string, val interface{}) {
+	parts := strings.Split(path, rutil.SplitToken)
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := dst[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			dst[part] = next
+		}
+		dst = next
+	}
+	dst[parts[len(parts)-1]] = val
+}
+
+func decodeBody(buf RawMessage) (interface{}, error) {
+	var body interface{}
+	if len(buf) == 0 {
+		return body, nil
+	}
+	err := json.Unmarshal(buf, &body)
+	return body, err
+}
@@ -0,0 +1,166 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.unistack.org/micro/v3/codec"
+	"go.unistack.org/micro/v3/store"
+)
+
+// lease marks execution as owned by worker until it expires
+type lease struct {
+	Expires time.Time `json:"expires"`
+	Owner   string    `json:"owner"`
+}
+
+func (w *microWorkflow) leaseStore(eid string) store.Store {
+	return store.NewNamespaceStore(w.opts.Store, "workflows"+w.opts.Store.Options().Separator+eid)
+}
+
+func (w *microWorkflow) readLease(ctx context.Context, eid string) (*lease, error) {
+	buf := &codec.Frame{}
+	if err := w.leaseStore(eid).Read(ctx, "lease", buf); err != nil {
+		return nil, err
+	}
+	l := &lease{}
+	if err := json.Unmarshal(buf.Data, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (w *microWorkflow) writeLease(ctx context.Context, eid string, owner string) error {
+	buf, err := json.Marshal(lease{Owner: owner, Expires: time.Now().Add(w.opts.LeaseTTL)})
+	if err != nil {
+		return err
+	}
+	return w.leaseStore(eid).Write(ctx, "lease", &codec.Frame{Data: buf}, store.WriteTTL(w.opts.LeaseTTL))
+}
+
+// lockLease locks execution lease by Sync if it set, returned func unlocks it
+func (w *microWorkflow) lockLease(eid string) (func(), error) {
+	if w.opts.Sync == nil {
+		return func() {}, nil
+	}
+	lockID := "flow/lease/" + eid
+	if err := w.opts.Sync.Lock(lockID); err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = w.opts.Sync.Unlock(lockID)
+	}, nil
+}
+
+// acquireLease takes execution lease for owner if it not held by other worker, zero ttl disables leasing
+func (w *microWorkflow) acquireLease(ctx context.Context, eid string, owner string) error {
+	if w.opts.LeaseTTL <= 0 {
+		return nil
+	}
+
+	unlock, err := w.lockLease(eid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	l, err := w.readLease(ctx, eid)
+	switch {
+	case err == store.ErrNotFound:
+	case err != nil:
+		return err
+	case l.Owner != owner && time.Now().Before(l.Expires):
+		return ErrExecutionLocked
+	}
+
+	if err = w.writeLease(ctx, eid, owner); err != nil {
+		return err
+	}
+
+	// read, check and write serialized by lock
+	if w.opts.Sync != nil {
+		return nil
+	}
+
+	// without lock check that lease not overwritten by other worker,
+	// lease already expired means no other worker holds it
+	if l, err = w.readLease(ctx, eid); err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if l.Owner != owner {
+		return ErrExecutionLocked
+	}
+
+	return nil
+}
+
+// renewLease extends execution lease until context done or lease taken by other worker
+func (w *microWorkflow) renewLease(ctx context.Context, eid string, owner string) {
+	if w.opts.LeaseTTL <= 0 {
+		return
+	}
+
+	interval := w.opts.LeaseTTL / 3
+	if interval <= 0 {
+		interval = w.opts.LeaseTTL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if ok, err := w.extendLease(ctx, eid, owner); err != nil {
+				w.opts.Logger.Errorf(ctx, "lease renew error: %v", err)
+			} else if !ok {
+				return
+			}
+		}
+	}
+}
+
+// extendLease writes new lease expiration if lease still held by owner
+func (w *microWorkflow) extendLease(ctx context.Context, eid string, owner string) (bool, error) {
+	unlock, err := w.lockLease(eid)
+	if err != nil {
+		return true, err
+	}
+	defer unlock()
+
+	l, err := w.readLease(ctx, eid)
+	if err == store.ErrNotFound || (err == nil && l.Owner != owner) {
+		return false, nil
+	} else if err != nil {
+		return true, err
+	}
+	return true, w.writeLease(ctx, eid, owner)
+}
+
+// releaseLease deletes execution lease if it held by owner
+func (w *microWorkflow) releaseLease(ctx context.Context, eid string, owner string) error {
+	if w.opts.LeaseTTL <= 0 {
+		return nil
+	}
+
+	unlock, err := w.lockLease(eid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	l, err := w.readLease(ctx, eid)
+	if err == store.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if l.Owner != owner {
+		return nil
+	}
+	return w.leaseStore(eid).Delete(ctx, "lease")
+}
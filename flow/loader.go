@@ -0,0 +1,186 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/silas/dag"
+	"go.unistack.org/micro/v3/codec"
+)
+
+// ErrMissingWorkflowID returns when workflow definition does not contain id
+var ErrMissingWorkflowID = errors.New("workflow id not set")
+
+// ParseWorkflowDefinition decodes workflow definition from buf with codec c and validates it.
+// Definition fields have json and yaml tags, so json and yaml codecs can be used.
+func ParseWorkflowDefinition(c codec.Codec, buf []byte) (*WorkflowDefinition, error) {
+	def := &WorkflowDefinition{}
+	if err := c.Unmarshal(buf, def); err != nil {
+		return nil, err
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// ExportWorkflow returns definition of the workflow including fallback and compensation steps,
+// definition can be encoded with codec and parsed back by ParseWorkflowDefinition
+func ExportWorkflow(w Workflow) (*WorkflowDefinition, error) {
+	var steps []Step
+	if mw, ok := w.(*microWorkflow); ok {
+		mw.RLock()
+		for _, step := range mw.steps {
+			steps = append(steps, step)
+		}
+		mw.RUnlock()
+	} else {
+		levels, err := w.Steps()
+		if err != nil {
+			return nil, err
+		}
+		for _, level := range levels {
+			steps = append(steps, level...)
+		}
+	}
+
+	return NewWorkflowDefinition(w.ID(), steps...)
+}
+
+// Validate checks that all step types registered, step ids unique, durations valid,
+// referenced steps exist and required steps does not form a cycle
+func (def *WorkflowDefinition) Validate() error {
+	if def.ID == "" {
+		return ErrMissingWorkflowID
+	}
+
+	steps, err := def.NewSteps()
+	if err != nil {
+		return err
+	}
+
+	all := make(map[string]Step, len(steps))
+	for _, step := range steps {
+		if _, ok := all[step.ID()]; ok {
+			return fmt.Errorf("step %s defined more than once", step.ID())
+		}
+		all[step.ID()] = step
+	}
+
+	for idx, step := range steps {
+		refs := append([]string{}, step.Requires()...)
+		if opts := step.Options(); opts.Fallback != "" {
+			refs = append(refs, opts.Fallback)
+		}
+		if opts := step.Options(); opts.Compensate != "" {
+			refs = append(refs, opts.Compensate)
+		}
+		for _, id := range def.Steps[idx].Branches {
+			refs = append(refs, id)
+		}
+		for _, id := range refs {
+			if _, ok := all[id]; !ok {
+				return fmt.Errorf("%w: step %s references %s", ErrStepNotExists, step.ID(), id)
+			}
+		}
+	}
+
+	// branch step selects which of its dependent steps executed
+	for idx, step := range steps {
+		for _, id := range def.Steps[idx].Branches {
+			required := false
+			for _, rid := range all[id].Requires() {
+				required = required || rid == step.ID()
+			}
+			if !required {
+				return fmt.Errorf("branch target %s of step %s must require it", id, step.ID())
+			}
+		}
+	}
+
+	// the same graph checks as in WorkflowCreate
+	g := &dag.AcyclicGraph{}
+	handlers := handlerSteps(all)
+	for _, step := range steps {
+		if _, ok := handlers[step.ID()]; !ok {
+			g.Add(step)
+		}
+	}
+	for _, dst := range steps {
+		if _, ok := handlers[dst.ID()]; ok {
+			continue
+		}
+		for _, id := range dst.Requires() {
+			g.Connect(dag.BasicEdge(all[id], dst))
+		}
+	}
+
+	return g.Validate()
+}
+
+// Workflow validates definition and creates workflow via f.WorkflowCreate
+func (def *WorkflowDefinition) Workflow(ctx context.Context, f Flow) (Workflow, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	steps, err := def.NewSteps()
+	if err != nil {
+		return nil, err
+	}
+
+	return f.WorkflowCreate(ctx, def.ID, steps...)
+}
+
+// DOT returns workflow graph in Graphviz DOT format.
+// Fallback steps connected by dashed edges, compensation steps by dotted edges,
+// edges from branch steps labeled with case.
+func (def *WorkflowDefinition) DOT() []byte {
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "digraph %s {\n", strconv.Quote(def.ID))
+
+	cases := make(map[string]map[string]string)
+	for _, sdef := range def.Steps {
+		for key, id := range sdef.Branches {
+			if cases[sdef.ID] == nil {
+				cases[sdef.ID] = make(map[string]string)
+			}
+			cases[sdef.ID][id] = key
+		}
+	}
+
+	for _, sdef := range def.Steps {
+		label := sdef.ID + "\n" + sdef.Type
+		switch {
+		case sdef.Service != "":
+			label += " " + sdef.Service + "." + sdef.Endpoint
+		case sdef.Endpoint != "":
+			label += " " + sdef.Endpoint
+		case sdef.Duration != "":
+			label += " " + sdef.Duration
+		}
+		fmt.Fprintf(buf, "\t%s [label=%s];\n", strconv.Quote(sdef.ID), strconv.Quote(label))
+	}
+
+	for _, sdef := range def.Steps {
+		for _, id := range sdef.Requires {
+			if key, ok := cases[id][sdef.ID]; ok {
+				fmt.Fprintf(buf, "\t%s -> %s [label=%s];\n", strconv.Quote(id), strconv.Quote(sdef.ID), strconv.Quote(key))
+				continue
+			}
+			fmt.Fprintf(buf, "\t%s -> %s;\n", strconv.Quote(id), strconv.Quote(sdef.ID))
+		}
+		if sdef.Fallback != "" {
+			fmt.Fprintf(buf, "\t%s -> %s [style=dashed, label=\"fallback\"];\n", strconv.Quote(sdef.ID), strconv.Quote(sdef.Fallback))
+		}
+		if sdef.Compensate != "" {
+			fmt.Fprintf(buf, "\t%s -> %s [style=dotted, label=\"compensate\"];\n", strconv.Quote(sdef.ID), strconv.Quote(sdef.Compensate))
+		}
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,114 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.unistack.org/micro/v3/codec"
+)
+
+const testWorkflowDefinition = `{
+  "id": "order",
+  "steps": [
+    {"type": "call", "id": "create", "service": "orders", "endpoint": "Orders.Create", "timeout": "5s", "retries": 2, "compensate": "cancel"},
+    {"type": "branch", "id": "check", "path": "$.kind", "branches": {"fast": "notify", "*": "wait"}, "requires": ["create"]},
+    {"type": "publish", "id": "notify", "endpoint": "orders.created", "requires": ["check"], "fallback": "backup"},
+    {"type": "delay", "id": "wait", "duration": "1s", "requires": ["check"]},
+    {"type": "publish", "id": "backup", "endpoint": "orders.backup"},
+    {"type": "call", "id": "cancel", "service": "orders", "endpoint": "Orders.Cancel", "input": {"id": "$.request.id"}}
+  ]
+}`
+
+func TestWorkflowDefinitionParse(t *testing.T) {
+	ctx := context.Background()
+	c := codec.NewCodec()
+
+	def, err := ParseWorkflowDefinition(c, []byte(testWorkflowDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := def.Workflow(ctx, NewFlow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := w.Steps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 3 || steps[0][0].ID() != "create" {
+		t.Fatalf("invalid steps %v", steps)
+	}
+
+	edef, err := ExportWorkflow(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := c.Marshal(edef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ndef, err := ParseWorkflowDefinition(c, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(edef, ndef) {
+		t.Fatalf("exported definition differs after parse\n%s", buf)
+	}
+	if sdef := ndef.Steps[3]; sdef.ID != "create" || sdef.Timeout != "5s" || sdef.Retries != 2 || sdef.Compensate != "cancel" {
+		t.Fatalf("invalid exported step %#v", sdef)
+	}
+}
+
+func TestWorkflowDefinitionValidate(t *testing.T) {
+	tests := map[string]string{
+		"missing id":     `{"steps":[{"type":"delay","id":"a","duration":"1s"}]}`,
+		"unknown type":   `{"id":"w","steps":[{"type":"unknown","id":"a"}]}`,
+		"duplicate":      `{"id":"w","steps":[{"type":"delay","id":"a","duration":"1s"},{"type":"delay","id":"a","duration":"2s"}]}`,
+		"bad timeout":    `{"id":"w","steps":[{"type":"delay","id":"a","duration":"1s","timeout":"soon"}]}`,
+		"unknown ref":    `{"id":"w","steps":[{"type":"delay","id":"a","duration":"1s","requires":["b"]}]}`,
+		"unknown branch": `{"id":"w","steps":[{"type":"branch","id":"a","path":"$.v","branches":{"x":"b"}}]}`,
+		"branch target":  `{"id":"w","steps":[{"type":"branch","id":"a","path":"$.v","branches":{"x":"b"}},{"type":"delay","id":"b","duration":"1s"}]}`,
+		"cycle":          `{"id":"w","steps":[{"type":"delay","id":"r","duration":"1s"},{"type":"delay","id":"a","duration":"1s","requires":["r","b"]},{"type":"delay","id":"b","duration":"1s","requires":["a"]}]}`,
+	}
+
+	for name, doc := range tests {
+		if _, err := ParseWorkflowDefinition(codec.NewCodec(), []byte(doc)); err == nil {
+			t.Fatalf("%s: validation must fail", name)
+		}
+	}
+
+	_, err := ParseWorkflowDefinition(codec.NewCodec(), []byte(tests["unknown ref"]))
+	if !errors.Is(err, ErrStepNotExists) {
+		t.Fatalf("expected ErrStepNotExists, got %v", err)
+	}
+
+	if _, err = NewStepFromDefinition(&StepDefinition{Type: "call", ID: "a", Timeout: "soon"}); err == nil {
+		t.Fatal("invalid timeout must be rejected")
+	}
+}
+
+func TestWorkflowDefinitionDOT(t *testing.T) {
+	def, err := ParseWorkflowDefinition(codec.NewCodec(), []byte(testWorkflowDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := string(def.DOT())
+	for _, line := range []string{
+		`digraph "order" {`,
+		`"create" [label="create\ncall orders.Orders.Create"];`,
+		`"create" -> "check";`,
+		`"check" -> "notify" [label="fast"];`,
+		`"check" -> "wait" [label="*"];`,
+		`"notify" -> "backup" [style=dashed, label="fallback"];`,
+		`"create" -> "cancel" [style=dotted, label="compensate"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Fatalf("dot does not contain %s\n%s", line, dot)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"go.unistack.org/micro/v3/logger"
 	"go.unistack.org/micro/v3/meter"
 	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/sync"
 	"go.unistack.org/micro/v3/tracer"
 )
 
@@ -28,16 +29,26 @@ type Options struct {
 	Meter meter.Meter
 	// Store used for intermediate results
 	Store store.Store
+	// EventTopic specifies broker topic for status change events, empty topic disables publishing
+	EventTopic string
+	// LeaseTTL specifies how long execution owned by worker without lease renewal, zero disables leasing
+	LeaseTTL time.Duration
+	// Sync used to lock execution lease while it checked and written
+	Sync sync.Sync
 }
 
+// DefaultLeaseTTL specifies default execution lease ttl
+var DefaultLeaseTTL = 30 * time.Second
+
 // NewOptions returns new options struct with default or passed values
 func NewOptions(opts ...Option) Options {
 	options := Options{
-		Context: context.Background(),
-		Logger:  logger.DefaultLogger,
-		Meter:   meter.DefaultMeter,
-		Tracer:  tracer.DefaultTracer,
-		Client:  client.DefaultClient,
+		Context:  context.Background(),
+		Logger:   logger.DefaultLogger,
+		Meter:    meter.DefaultMeter,
+		Tracer:   tracer.DefaultTracer,
+		Client:   client.DefaultClient,
+		LeaseTTL: DefaultLeaseTTL,
 	}
 
 	for _, o := range opts {
@@ -91,6 +102,29 @@ func Store(s store.Store) Option {
 	}
 }
 
+// LeaseTTL sets the execution lease ttl, worker that runs execution renews lease
+// while it running, so execution with expired lease can be resumed by other worker
+func LeaseTTL(td time.Duration) Option {
+	return func(o *Options) {
+		o.LeaseTTL = td
+	}
+}
+
+// Sync sets the sync.Sync used to lock execution lease by execution id while it checked and written,
+// without it store has no compare and swap and workers that take the same lease at the same time can both own it
+func Sync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
+// EventTopic sets the broker topic used to publish execution and step status change events
+func EventTopic(topic string) Option {
+	return func(o *Options) {
+		o.EventTopic = topic
+	}
+}
+
 // WorkflowOption func signature
 type WorkflowOption func(*WorkflowOptions)
 
@@ -111,6 +145,8 @@ func WorkflowID(id string) WorkflowOption {
 type ExecuteOptions struct {
 	// Client holds the client.Client
 	Client client.Client
+	// Store holds the store.Store
+	Store store.Store
 	// Tracer holds the tracer
 	Tracer tracer.Tracer
 	// Logger holds the logger
@@ -119,14 +155,24 @@ type ExecuteOptions struct {
 	Meter meter.Meter
 	// Context can be used to abort execution or pass additional opts
 	Context context.Context
+	// EventTopic holds the workflow events topic, passed to sub workflows
+	EventTopic string
+	// LeaseTTL holds the workflow execution lease ttl, passed to sub workflows
+	LeaseTTL time.Duration
+	// Sync holds the execution lease lock, passed to sub workflows
+	Sync sync.Sync
 	// Start step
 	Start string
 	// Timeout for execution
 	Timeout time.Duration
+	// MaxConcurrency limits the number of steps running in parallel, zero means no limit
+	MaxConcurrency int
 	// Reverse execution
 	Reverse bool
 	// Async enables async execution
 	Async bool
+	// ContinueOnError runs independent steps after step failure instead of cancel execution
+	ContinueOnError bool
 }
 
 // ExecuteOption func signature
@@ -160,6 +206,13 @@ func ExecuteMeter(m meter.Meter) ExecuteOption {
 	}
 }
 
+// ExecuteStore pass store.Store to ExecuteOption
+func ExecuteStore(s store.Store) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Store = s
+	}
+}
+
 // ExecuteContext pass context.Context ot ExecuteOption
 func ExecuteContext(ctx context.Context) ExecuteOption {
 	return func(o *ExecuteOptions) {
@@ -167,6 +220,27 @@ func ExecuteContext(ctx context.Context) ExecuteOption {
 	}
 }
 
+// ExecuteEventTopic pass events topic to ExecuteOption
+func ExecuteEventTopic(topic string) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.EventTopic = topic
+	}
+}
+
+// ExecuteLeaseTTL pass execution lease ttl to ExecuteOption
+func ExecuteLeaseTTL(td time.Duration) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.LeaseTTL = td
+	}
+}
+
+// ExecuteSync pass execution lease lock to ExecuteOption
+func ExecuteSync(s sync.Sync) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Sync = s
+	}
+}
+
 // ExecuteReverse says that dag must be run in reverse order
 func ExecuteReverse(b bool) ExecuteOption {
 	return func(o *ExecuteOptions) {
@@ -188,14 +262,30 @@ func ExecuteAsync(b bool) ExecuteOption {
 	}
 }
 
+// ExecuteMaxConcurrency limits the number of steps running in parallel
+func ExecuteMaxConcurrency(n int) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.MaxConcurrency = n
+	}
+}
+
+// ExecuteContinueOnError says that steps not depending on failed step must be executed,
+// by default execution fails fast and running steps cancelled
+func ExecuteContinueOnError(b bool) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.ContinueOnError = b
+	}
+}
+
 // NewExecuteOptions create new ExecuteOptions struct
 func NewExecuteOptions(opts ...ExecuteOption) ExecuteOptions {
 	options := ExecuteOptions{
-		Client:  client.DefaultClient,
-		Logger:  logger.DefaultLogger,
-		Tracer:  tracer.DefaultTracer,
-		Meter:   meter.DefaultMeter,
-		Context: context.Background(),
+		Client:   client.DefaultClient,
+		Logger:   logger.DefaultLogger,
+		Tracer:   tracer.DefaultTracer,
+		Meter:    meter.DefaultMeter,
+		Context:  context.Background(),
+		LeaseTTL: DefaultLeaseTTL,
 	}
 	for _, o := range opts {
 		o(&options)
@@ -205,10 +295,17 @@ func NewExecuteOptions(opts ...ExecuteOption) ExecuteOptions {
 
 // StepOptions holds step options
 type StepOptions struct {
-	Context  context.Context
-	Fallback string
-	ID       string
-	Requires []string
+	Context        context.Context
+	Input          map[string]string
+	Retry          client.RetryFunc
+	Backoff        client.BackoffFunc
+	Fallback       string
+	Compensate     string
+	ID             string
+	IdempotencyKey string
+	Requires       []string
+	Timeout        time.Duration
+	Retries        int
 }
 
 // StepOption func signature
@@ -239,9 +336,170 @@ func StepRequires(steps ...string) StepOption {
 	}
 }
 
-// StepFallback set the step to run on error
+// StepFallback set the step to run on error, if fallback step succeeded
+// its response used as response of the failed step
 func StepFallback(step string) StepOption {
 	return func(o *StepOptions) {
 		o.Fallback = step
 	}
 }
+
+// StepCompensate set the step to run if workflow failed after this step completed,
+// compensation step receives response of the completed step
+func StepCompensate(step string) StepOption {
+	return func(o *StepOptions) {
+		o.Compensate = step
+	}
+}
+
+// StepInput sets the step request mapping, keys are dot separated fields of request body,
+// values are util/reflect.Lookup paths like $.step_id.field over required steps responses
+// and workflow request available as $.request, step id with dots matched by longest prefix,
+// values without $ prefix used as is
+func StepInput(input map[string]string) StepOption {
+	return func(o *StepOptions) {
+		o.Input = input
+	}
+}
+
+// StepRetries sets the number of step retries after first failed attempt
+func StepRetries(n int) StepOption {
+	return func(o *StepOptions) {
+		o.Retries = n
+	}
+}
+
+// StepRetry sets the func that checks that failed step must be retried, by default step retried on any error
+func StepRetry(fn client.RetryFunc) StepOption {
+	return func(o *StepOptions) {
+		o.Retry = fn
+	}
+}
+
+// StepBackoff sets the func that returns delay before next step attempt, by default client.DefaultBackoff used
+func StepBackoff(fn client.BackoffFunc) StepOption {
+	return func(o *StepOptions) {
+		o.Backoff = fn
+	}
+}
+
+// StepTimeout sets the timeout for each step attempt, built-in steps apply it when executed outside of workflow too
+func StepTimeout(td time.Duration) StepOption {
+	return func(o *StepOptions) {
+		o.Timeout = td
+	}
+}
+
+// StepIdempotencyKey sets the key passed in metadata.HeaderIdempotencyKey request header,
+// key that starts with $ is util/reflect.Lookup path over the request body
+func StepIdempotencyKey(key string) StepOption {
+	return func(o *StepOptions) {
+		o.IdempotencyKey = key
+	}
+}
+
+// ListOptions holds execution list options
+type ListOptions struct {
+	// From filters executions created at or after time
+	From time.Time
+	// To filters executions created before time
+	To time.Time
+	// Workflow filters executions of workflow
+	Workflow string
+	// Status filters executions with status
+	Status []Status
+}
+
+// ListOption func signature
+type ListOption func(*ListOptions)
+
+// NewListOptions create new ListOptions struct
+func NewListOptions(opts ...ListOption) ListOptions {
+	options := ListOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// ListWorkflow returns executions of specific workflow
+func ListWorkflow(id string) ListOption {
+	return func(o *ListOptions) {
+		o.Workflow = id
+	}
+}
+
+// ListStatus returns executions with one of statuses
+func ListStatus(statuses ...Status) ListOption {
+	return func(o *ListOptions) {
+		o.Status = statuses
+	}
+}
+
+// ListTimeRange returns executions created in [from, to) range, zero time means no limit
+func ListTimeRange(from time.Time, to time.Time) ListOption {
+	return func(o *ListOptions) {
+		o.From = from
+		o.To = to
+	}
+}
+
+// SchedulerOptions holds scheduler options
+type SchedulerOptions struct {
+	// Sync used for leader election, only leader fires schedules, nil means no election
+	Sync sync.Sync
+	// Leader holds the leader election id
+	Leader string
+	// MinInterval is the min interval between checks for due schedules
+	MinInterval time.Duration
+	// MaxInterval is the max interval between checks for due schedules
+	MaxInterval time.Duration
+	// MaxCatchUp limits the number of missed runs fired by MissedRunAll policy
+	MaxCatchUp int
+}
+
+// SchedulerOption func signature
+type SchedulerOption func(*SchedulerOptions)
+
+// NewSchedulerOptions create new SchedulerOptions struct
+func NewSchedulerOptions(opts ...SchedulerOption) SchedulerOptions {
+	options := SchedulerOptions{
+		Leader:      "flow/scheduler",
+		MinInterval: time.Second,
+		MaxInterval: 2 * time.Second,
+		MaxCatchUp:  100,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// SchedulerSync sets the sync.Sync used for leader election, so only one scheduler instance fires schedules
+func SchedulerSync(s sync.Sync) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Sync = s
+	}
+}
+
+// SchedulerLeader sets the leader election id, schedulers with different ids fires schedules independently
+func SchedulerLeader(id string) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Leader = id
+	}
+}
+
+// SchedulerInterval sets the min and max interval between checks for due schedules
+func SchedulerInterval(min, max time.Duration) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.MinInterval = min
+		o.MaxInterval = max
+	}
+}
+
+// SchedulerMaxCatchUp limits the number of missed runs fired by MissedRunAll policy
+func SchedulerMaxCatchUp(n int) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.MaxCatchUp = n
+	}
+}
@@ -0,0 +1,176 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.unistack.org/micro/v3/client"
+	"go.unistack.org/micro/v3/codec"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/tracer"
+	rutil "go.unistack.org/micro/v3/util/reflect"
+)
+
+var (
+	// StepDurationSeconds specifies meter metric name for step attempt duration
+	StepDurationSeconds = "flow_step_duration_seconds"
+	// StepAttempts specifies meter metric name for number of step attempts
+	StepAttempts = "flow_step_attempts"
+
+	labelWorkflow = "workflow"
+	labelStep     = "step"
+	labelStatus   = "status"
+	labelSuccess  = "success"
+	labelFailure  = "failure"
+)
+
+// executeAttempts runs step with the smaller of step and execute timeouts until it succeeded or retry policy allows next attempt,
+// number of attempts written to the store
+func (w *microWorkflow) executeAttempts(ctx context.Context, eid string, step Step, req *Message, opts []ExecuteOption) (*Message, error) {
+	sopts := step.Options()
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+
+	retry := sopts.Retry
+	if retry == nil {
+		retry = client.RetryAlways
+	}
+	backoff := sopts.Backoff
+	if backoff == nil {
+		backoff = client.DefaultBackoff
+	}
+
+	// step timeout must not extend smaller workflow execute timeout
+	if td := NewExecuteOptions(opts...).Timeout; sopts.Timeout > 0 && (td <= 0 || sopts.Timeout < td) {
+		opts = append(opts, ExecuteTimeout(sopts.Timeout))
+	}
+
+	ctx, sp := w.opts.Tracer.Start(ctx, "Flow.Step "+step.ID(),
+		tracer.WithSpanKind(tracer.SpanKindInternal),
+		tracer.WithSpanLabels(labelWorkflow, w.id, labelStep, step.ID()),
+	)
+	defer sp.Finish()
+
+	var creq client.Request
+	var rsp *Message
+	var err error
+	attempt := 0
+
+	for {
+		attempt++
+
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if sopts.Timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, sopts.Timeout)
+		}
+		ts := time.Now()
+		rsp, err = step.Execute(actx, req, opts...)
+		cancel()
+
+		status := labelSuccess
+		if err != nil {
+			status = labelFailure
+			sp.AddEvent(fmt.Sprintf("attempt %d: %v", attempt, err))
+		}
+		w.opts.Meter.Histogram(StepDurationSeconds, labelWorkflow, w.id, labelStep, step.ID(), labelStatus, status).UpdateDuration(ts)
+
+		if werr := stepStore.Write(ctx, step.ID()+sep+"attempts", &codec.Frame{Data: []byte(strconv.Itoa(attempt))}); werr != nil {
+			w.opts.Logger.Errorf(ctx, "store write error: %v", werr)
+		}
+
+		if err == nil || attempt > sopts.Retries || ctx.Err() != nil {
+			break
+		}
+
+		if creq == nil && w.opts.Client != nil {
+			creq = stepClientRequest(w.opts.Client, step, req)
+		}
+		if ok, rerr := retry(ctx, creq, attempt, err); rerr != nil || !ok {
+			break
+		}
+		td, berr := backoff(ctx, creq, attempt)
+		if berr != nil {
+			break
+		}
+		if td > 0 {
+			timer := time.NewTimer(td)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	sp.AddLabels("attempts", attempt)
+	status := labelSuccess
+	if err != nil {
+		status = labelFailure
+		sp.SetStatus(tracer.SpanStatusError, err.Error())
+	}
+	w.opts.Meter.Histogram(StepAttempts, labelWorkflow, w.id, labelStep, step.ID(), labelStatus, status).Update(float64(attempt))
+
+	return rsp, err
+}
+
+// stepContext returns context expired after step timeout and lowers execute timeout to it,
+// so step executed outside of workflow honours its timeout too
+func stepContext(ctx context.Context, sopts StepOptions, options *ExecuteOptions) (context.Context, context.CancelFunc) {
+	if sopts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	if options.Timeout <= 0 || sopts.Timeout < options.Timeout {
+		options.Timeout = sopts.Timeout
+	}
+	return context.WithTimeout(ctx, sopts.Timeout)
+}
+
+// stepClientRequest returns client.Request passed to retry and backoff funcs
+func stepClientRequest(c client.Client, step Step, req *Message) client.Request {
+	if s, ok := step.(*microCallStep); ok {
+		return c.NewRequest(s.service, s.method, &codec.Frame{Data: req.Body})
+	}
+	return c.NewRequest(step.ID(), step.Endpoint(), &codec.Frame{Data: req.Body})
+}
+
+// idempotencyKey returns step idempotency key, key that starts with $ is
+// util/reflect.Lookup path over the request body, other keys used as is
+func idempotencyKey(key string, req *Message) (string, error) {
+	if !strings.HasPrefix(key, "$") {
+		return key, nil
+	}
+	body, err := decodeBody(req.Body)
+	if err != nil {
+		return "", err
+	}
+	val, err := rutil.Lookup(body, key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(val.Interface()), nil
+}
+
+// withIdempotencyKey returns copy of request with metadata.HeaderIdempotencyKey header
+func withIdempotencyKey(step Step, req *Message) (*Message, error) {
+	key := step.Options().IdempotencyKey
+	if key == "" {
+		return req, nil
+	}
+	val, err := idempotencyKey(key, req)
+	if err != nil {
+		return nil, err
+	}
+	nreq := &Message{Header: metadata.Copy(req.Header), Body: req.Body}
+	if nreq.Header == nil {
+		nreq.Header = metadata.New(1)
+	}
+	nreq.Header.Set(metadata.HeaderIdempotencyKey, val)
+	return nreq, nil
+}
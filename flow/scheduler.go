@@ -0,0 +1,422 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.unistack.org/micro/v3/codec"
+	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/util/jitter"
+)
+
+var (
+	// ErrInvalidSchedule returns when schedule has no id, workflow or has not exactly one of cron or interval
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	// ErrScheduleNotExists returns when schedule not found
+	ErrScheduleNotExists = errors.New("schedule not exists")
+)
+
+// MissedPolicy specifies how scheduler handles runs missed while no scheduler instance was running
+type MissedPolicy int
+
+const (
+	// MissedSkip skips missed runs, schedule fired at the next planned time
+	MissedSkip MissedPolicy = iota
+	// MissedRunOnce fires one execution for all missed runs
+	MissedRunOnce
+	// MissedRunAll fires execution for each missed run, limited by SchedulerMaxCatchUp
+	MissedRunAll
+)
+
+// Schedule holds periodic workflow execution settings
+type Schedule struct {
+	// ID of the schedule
+	ID string `json:"id"`
+	// Workflow id, workflow must be saved via WorkflowSave
+	Workflow string `json:"workflow"`
+	// Cron expression, five fields or descriptor like @hourly
+	Cron string `json:"cron,omitempty"`
+	// Interval between executions
+	Interval time.Duration `json:"interval,omitempty"`
+	// Jitter adds random delay up to jitter to each interval
+	Jitter time.Duration `json:"jitter,omitempty"`
+	// Missed specifies policy for missed runs
+	Missed MissedPolicy `json:"missed,omitempty"`
+	// Request body passed to workflow execution, empty object used if not set
+	Request json.RawMessage `json:"request,omitempty"`
+}
+
+// scheduleState holds schedule runs persisted in store
+type scheduleState struct {
+	LastRun time.Time `json:"last_run,omitempty"`
+	NextRun time.Time `json:"next_run"`
+}
+
+// Scheduler triggers workflow executions by schedules stored in flow store
+type Scheduler interface {
+	// Options returns scheduler options
+	Options() SchedulerOptions
+	// ScheduleAdd adds or replaces schedule, next run planned from current time
+	ScheduleAdd(ctx context.Context, s *Schedule) error
+	// ScheduleRemove removes schedule with specific id
+	ScheduleRemove(ctx context.Context, id string) error
+	// ScheduleList lists all schedules
+	ScheduleList(ctx context.Context) ([]*Schedule, error)
+	// Start runs scheduler in background, schedules fired only while scheduler is leader
+	Start(ctx context.Context) error
+	// Stop stops scheduler and resigns leadership, started executions not aborted
+	Stop(ctx context.Context) error
+}
+
+type microScheduler struct {
+	f      Flow
+	cancel context.CancelFunc
+	done   chan struct{}
+	opts   SchedulerOptions
+	sync.RWMutex
+	leader bool
+}
+
+// NewScheduler returns scheduler that persists schedules in the flow store and executes flow workflows
+func NewScheduler(f Flow, opts ...SchedulerOption) Scheduler {
+	return &microScheduler{f: f, opts: NewSchedulerOptions(opts...)}
+}
+
+func (s *microScheduler) Options() SchedulerOptions {
+	return s.opts
+}
+
+func (s *microScheduler) ScheduleAdd(ctx context.Context, sc *Schedule) error {
+	fopts := s.f.Options()
+	if fopts.Store == nil {
+		return ErrMissingStore
+	}
+
+	if sc.ID == "" || sc.Workflow == "" || (sc.Cron == "") == (sc.Interval <= 0) {
+		return ErrInvalidSchedule
+	}
+	next, err := s.next(sc, time.Now())
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(sc)
+	if err != nil {
+		return err
+	}
+	if err = store.NewNamespaceStore(fopts.Store, "schedules").Write(ctx, sc.ID, &codec.Frame{Data: buf}); err != nil {
+		return err
+	}
+
+	state, err := s.readState(ctx, sc.ID)
+	if err != nil {
+		return err
+	}
+	state.NextRun = next
+
+	return s.writeState(ctx, sc.ID, state)
+}
+
+func (s *microScheduler) ScheduleRemove(ctx context.Context, id string) error {
+	fopts := s.f.Options()
+	if fopts.Store == nil {
+		return ErrMissingStore
+	}
+
+	if err := store.NewNamespaceStore(fopts.Store, "schedules").Delete(ctx, id); err == store.ErrNotFound {
+		return ErrScheduleNotExists
+	} else if err != nil {
+		return err
+	}
+
+	if err := store.NewNamespaceStore(fopts.Store, "runs").Delete(ctx, id); err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+func (s *microScheduler) ScheduleList(ctx context.Context) ([]*Schedule, error) {
+	fopts := s.f.Options()
+	if fopts.Store == nil {
+		return nil, ErrMissingStore
+	}
+
+	scheduleStore := store.NewNamespaceStore(fopts.Store, "schedules")
+	ids, err := scheduleStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	schedules := make([]*Schedule, 0, len(ids))
+	for _, id := range ids {
+		buf := &codec.Frame{}
+		if err = scheduleStore.Read(ctx, id, buf); err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		sc := &Schedule{}
+		if err = json.Unmarshal(buf.Data, sc); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sc)
+	}
+
+	return schedules, nil
+}
+
+func (s *microScheduler) Start(ctx context.Context) error {
+	if s.f.Options().Store == nil {
+		return ErrMissingStore
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.cancel != nil {
+		return nil
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
+
+	return nil
+}
+
+func (s *microScheduler) Stop(ctx context.Context) error {
+	s.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isLeader returns true while scheduler fires schedules
+func (s *microScheduler) isLeader() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.leader
+}
+
+func (s *microScheduler) setLeader(b bool) {
+	s.Lock()
+	s.leader = b
+	s.Unlock()
+}
+
+// run elects leader and fires schedules until context done
+func (s *microScheduler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	fopts := s.f.Options()
+
+	for {
+		if s.opts.Sync == nil {
+			s.lead(ctx, nil)
+			return
+		}
+
+		// blocks until leadership acquired
+		leader, err := s.opts.Sync.Leader(s.opts.Leader)
+		if err != nil {
+			fopts.Logger.Errorf(ctx, "scheduler leader election error: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.opts.MaxInterval):
+			}
+			continue
+		}
+
+		if ctx.Err() == nil {
+			s.lead(ctx, leader.Status())
+		}
+
+		if err = leader.Resign(); err != nil {
+			fopts.Logger.Errorf(ctx, "scheduler leader resign error: %v", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// lead fires due schedules until context done or leadership lost
+func (s *microScheduler) lead(ctx context.Context, status chan bool) {
+	s.setLeader(true)
+	defer s.setLeader(false)
+
+	min, max := s.opts.MinInterval, s.opts.MaxInterval
+	if max <= min {
+		max = min + time.Millisecond
+	}
+	ticker := jitter.NewTickerContext(ctx, min, max)
+	defer ticker.Stop()
+
+	s.tick(ctx, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-status:
+			return
+		case t := <-ticker.C:
+			s.tick(ctx, t)
+		}
+	}
+}
+
+// tick fires all schedules due at now
+func (s *microScheduler) tick(ctx context.Context, now time.Time) {
+	fopts := s.f.Options()
+
+	schedules, err := s.ScheduleList(ctx)
+	if err != nil {
+		fopts.Logger.Errorf(ctx, "scheduler list error: %v", err)
+		return
+	}
+
+	for _, sc := range schedules {
+		if err = s.fire(ctx, sc, now); err != nil {
+			fopts.Logger.Errorf(ctx, "schedule %s error: %v", sc.ID, err)
+		}
+	}
+}
+
+// fire executes workflow for due runs of the schedule according to its missed policy,
+// state written before execution, so run never fired twice
+func (s *microScheduler) fire(ctx context.Context, sc *Schedule, now time.Time) error {
+	state, err := s.readState(ctx, sc.ID)
+	if err != nil {
+		return err
+	}
+	if state.NextRun.IsZero() {
+		if state.NextRun, err = s.next(sc, now); err != nil {
+			return err
+		}
+		return s.writeState(ctx, sc.ID, state)
+	}
+
+	limit := s.opts.MaxCatchUp
+	if limit < 1 {
+		limit = 1
+	}
+
+	var due []time.Time
+	for !state.NextRun.IsZero() && !state.NextRun.After(now) {
+		if len(due) == limit {
+			// too many missed runs, plan next run from now
+			if state.NextRun, err = s.next(sc, now); err != nil {
+				return err
+			}
+			break
+		}
+		due = append(due, state.NextRun)
+		if state.NextRun, err = s.next(sc, state.NextRun); err != nil {
+			return err
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	state.LastRun = due[len(due)-1]
+
+	switch sc.Missed {
+	case MissedRunAll:
+	case MissedRunOnce:
+		due = due[len(due)-1:]
+	default:
+		// run planned before the previous check is missed
+		if now.Sub(due[len(due)-1]) > 2*s.opts.MaxInterval {
+			due = nil
+		} else {
+			due = due[len(due)-1:]
+		}
+	}
+
+	if err = s.writeState(ctx, sc.ID, state); err != nil {
+		return err
+	}
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	body := RawMessage(sc.Request)
+	if len(body) == 0 {
+		body = RawMessage(`{}`)
+	}
+
+	// execution state kept in store, so one workflow instance runs all due executions
+	w, err := s.f.WorkflowLoad(ctx, sc.Workflow)
+	if err != nil {
+		return err
+	}
+	for range due {
+		// execution must not be aborted when scheduler stopped
+		if _, err = w.Execute(s.f.Options().Context, &Message{Body: body}, ExecuteAsync(true)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// next returns next run time of the schedule after t
+func (s *microScheduler) next(sc *Schedule, t time.Time) (time.Time, error) {
+	if sc.Cron != "" {
+		c, err := parseCron(sc.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return c.next(t), nil
+	}
+	if sc.Interval <= 0 {
+		return time.Time{}, ErrInvalidSchedule
+	}
+	if sc.Jitter > 0 {
+		return t.Add(jitter.RandomInterval(sc.Interval, sc.Interval+sc.Jitter)), nil
+	}
+	return t.Add(sc.Interval), nil
+}
+
+func (s *microScheduler) readState(ctx context.Context, id string) (*scheduleState, error) {
+	state := &scheduleState{}
+	buf := &codec.Frame{}
+	if err := store.NewNamespaceStore(s.f.Options().Store, "runs").Read(ctx, id, buf); err == store.ErrNotFound {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf.Data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *microScheduler) writeState(ctx context.Context, id string, state *scheduleState) error {
+	buf, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return store.NewNamespaceStore(s.f.Options().Store, "runs").Write(ctx, id, &codec.Frame{Data: buf})
+}
@@ -0,0 +1,142 @@
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/sync"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC)
+	tests := map[string]time.Time{
+		"* * * * *":       time.Date(2024, time.January, 31, 10, 18, 0, 0, time.UTC),
+		"*/15 * * * *":    time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC),
+		"5,20-22 * * * *": time.Date(2024, time.January, 31, 10, 20, 0, 0, time.UTC),
+		"0 9 * * *":       time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":      time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":       time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * 6":       time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"@monthly":        time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for expr, expected := range tests {
+		c, err := parseCron(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := c.next(from); !next.Equal(expected) {
+			t.Fatalf("%s: expected %s, got %s", expr, expected, next)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Fatalf("%s: parse must fail", expr)
+		}
+	}
+}
+
+func newTestScheduledFlow(t *testing.T, s store.Store) Flow {
+	ctx := context.Background()
+	f := NewFlow(Store(s))
+	w, err := f.WorkflowCreate(ctx, "workflow", NewDelayStep(time.Millisecond, StepID("delay")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.WorkflowSave(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestSchedulerMissedPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	for policy, expected := range map[MissedPolicy]int{MissedSkip: 0, MissedRunOnce: 1, MissedRunAll: 3} {
+		f := newTestScheduledFlow(t, store.NewStore())
+		s := NewScheduler(f).(*microScheduler)
+
+		if err := s.ScheduleAdd(ctx, &Schedule{ID: "hourly", Workflow: "workflow", Interval: time.Hour, Missed: policy}); err != nil {
+			t.Fatal(err)
+		}
+
+		// scheduler was not running for three runs
+		now := time.Now()
+		if err := s.writeState(ctx, "hourly", &scheduleState{NextRun: now.Add(-3*time.Hour + time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		s.tick(ctx, now)
+
+		executions, err := f.(ExecutionHistory).ExecutionList(ctx, ListWorkflow("workflow"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(executions) != expected {
+			t.Fatalf("policy %d: expected %d executions, got %d", policy, expected, len(executions))
+		}
+
+		state, err := s.readState(ctx, "hourly")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !state.NextRun.After(now) || !state.LastRun.Before(now) {
+			t.Fatalf("policy %d: invalid state %#v", policy, state)
+		}
+	}
+}
+
+func TestSchedulerLeader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	st := store.NewStore()
+	sy := sync.NewSync()
+	f := newTestScheduledFlow(t, st)
+
+	s1 := NewScheduler(f, SchedulerSync(sy), SchedulerInterval(5*time.Millisecond, 10*time.Millisecond)).(*microScheduler)
+	s2 := NewScheduler(NewFlow(Store(st)), SchedulerSync(sy), SchedulerInterval(5*time.Millisecond, 10*time.Millisecond)).(*microScheduler)
+
+	if err := s1.ScheduleAdd(ctx, &Schedule{ID: "often", Workflow: "workflow", Interval: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s1.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for !s1.isLeader() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s2.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		executions, err := f.(ExecutionHistory).ExecutionList(ctx, ListWorkflow("workflow"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(executions) >= 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s2.isLeader() {
+		t.Fatal("only one scheduler must be leader")
+	}
+
+	if err := s1.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for !s2.isLeader() {
+		if ctx.Err() != nil {
+			t.Fatal("second scheduler does not become leader")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := s2.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
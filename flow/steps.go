@@ -0,0 +1,482 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/store"
+	rutil "go.unistack.org/micro/v3/util/reflect"
+)
+
+var (
+	// ErrMissingCorrelationID returns when wait step request does not contain correlation id
+	ErrMissingCorrelationID = errors.New("correlation id not set")
+	// ErrBranchNotFound returns when branch step value does not match any branch
+	ErrBranchNotFound = errors.New("branch not found")
+)
+
+// BranchDefault is the branch key used when value does not match other branches
+const BranchDefault = "*"
+
+func init() {
+	RegisterStep(&microDelayStep{})
+	RegisterStep(&microWaitStep{})
+	RegisterStep(&microBranchStep{})
+	RegisterStep(&microWorkflowStep{})
+}
+
+// baseStep holds fields and methods common for all steps, step shared by parallel executions,
+// so it holds no execution state, requests and responses of execution steps kept in the store
+type baseStep struct {
+	opts   StepOptions
+	status Status
+}
+
+func (s *baseStep) Request() *Message {
+	return nil
+}
+
+func (s *baseStep) Response() *Message {
+	return nil
+}
+
+func (s *baseStep) Options() StepOptions {
+	return s.opts
+}
+
+func (s *baseStep) Requires() []string {
+	return s.opts.Requires
+}
+
+func (s *baseStep) Require(steps ...Step) error {
+	for _, step := range steps {
+		s.opts.Requires = append(s.opts.Requires, step.String())
+	}
+	return nil
+}
+
+func (s *baseStep) GetStatus() Status {
+	return s.status
+}
+
+func (s *baseStep) SetStatus(status Status) {
+	s.status = status
+}
+
+// id returns step id or name if id not set
+func (s *baseStep) id(name string) string {
+	if s.opts.ID != "" {
+		return s.opts.ID
+	}
+	return name
+}
+
+type microDelayStep struct {
+	baseStep
+	delay time.Duration
+}
+
+func (s *microDelayStep) ID() string {
+	return s.String()
+}
+
+func (s *microDelayStep) Endpoint() string {
+	return ""
+}
+
+func (s *microDelayStep) String() string {
+	return s.id("delay." + s.delay.String())
+}
+
+func (s *microDelayStep) Name() string {
+	return s.String()
+}
+
+func (s *microDelayStep) Hashcode() interface{} {
+	return s.String()
+}
+
+func (s *microDelayStep) Type() string {
+	return "delay"
+}
+
+func (s *microDelayStep) MarshalStep() (*StepDefinition, error) {
+	def := newStepDefinition(s.Type(), s.ID(), s.opts)
+	def.Duration = s.delay.String()
+	return def, nil
+}
+
+func (s *microDelayStep) UnmarshalStep(def *StepDefinition) (Step, error) {
+	delay, err := time.ParseDuration(def.Duration)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := def.stepOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &microDelayStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, delay: delay}, nil
+}
+
+func (s *microDelayStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
+	options := NewExecuteOptions(opts...)
+	ctx, cancel := stepContext(ctx, s.opts, &options)
+	defer cancel()
+
+	timer := time.NewTimer(s.delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+	return req, nil
+}
+
+// NewDelayStep create new step that pauses execution for specified duration,
+// step passes its request to dependent steps
+func NewDelayStep(delay time.Duration, opts ...StepOption) Step {
+	return &microDelayStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, delay: delay}
+}
+
+type microWaitStep struct {
+	baseStep
+	topic string
+	path  string
+}
+
+func (s *microWaitStep) ID() string {
+	return s.String()
+}
+
+func (s *microWaitStep) Endpoint() string {
+	return s.topic
+}
+
+func (s *microWaitStep) String() string {
+	return s.id("wait." + s.topic)
+}
+
+func (s *microWaitStep) Name() string {
+	return s.String()
+}
+
+func (s *microWaitStep) Hashcode() interface{} {
+	return s.String()
+}
+
+func (s *microWaitStep) Type() string {
+	return "wait"
+}
+
+func (s *microWaitStep) MarshalStep() (*StepDefinition, error) {
+	def := newStepDefinition(s.Type(), s.ID(), s.opts)
+	def.Endpoint = s.topic
+	def.Path = s.path
+	return def, nil
+}
+
+func (s *microWaitStep) UnmarshalStep(def *StepDefinition) (Step, error) {
+	opts, err := def.stepOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &microWaitStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, topic: def.Endpoint, path: def.Path}, nil
+}
+
+// correlationID returns correlation id from request body by path or from request header
+func (s *microWaitStep) correlationID(req *Message) (string, error) {
+	if s.path == "" {
+		if cid, ok := req.Header.Get(metadata.HeaderCorrelationID); ok && cid != "" {
+			return cid, nil
+		}
+		return "", ErrMissingCorrelationID
+	}
+
+	body, err := decodeBody(req.Body)
+	if err != nil {
+		return "", err
+	}
+	val, err := rutil.Lookup(body, s.path)
+	if err != nil {
+		return "", err
+	}
+	if !val.IsValid() || rutil.IsEmpty(val) {
+		return "", ErrMissingCorrelationID
+	}
+	return fmt.Sprint(val.Interface()), nil
+}
+
+func (s *microWaitStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
+	options := NewExecuteOptions(opts...)
+	if options.Client == nil {
+		return nil, ErrMissingClient
+	}
+
+	cid, err := s.correlationID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := stepContext(ctx, s.opts, &options)
+	defer cancel()
+
+	wb, ok := waitBufferFromContext(ctx, s.ID())
+	if !ok {
+		if wb, err = newWaitBuffer(ctx, options.Client.Options().Broker, s.topic); err != nil {
+			return nil, err
+		}
+		defer wb.stop()
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	msg, err := wb.wait(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Header: metadata.Copy(msg.Header), Body: RawMessage(msg.Body)}, nil
+}
+
+// NewWaitStep create new step that waits for broker message on the topic with the same
+// correlation id header as request. Correlation id taken from the request header
+// metadata.HeaderCorrelationID, or if path not empty, from request body via util/reflect.Lookup path.
+// Inside workflow step subscribed when execution started, so message published in reply to earlier steps
+// not lost, step executed directly subscribes on execute. Received message used as step response.
+func NewWaitStep(topic string, path string, opts ...StepOption) Step {
+	return &microWaitStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, topic: topic, path: path}
+}
+
+type microBranchStep struct {
+	baseStep
+	cases map[string]string
+	path  string
+}
+
+func (s *microBranchStep) ID() string {
+	return s.String()
+}
+
+func (s *microBranchStep) Endpoint() string {
+	return ""
+}
+
+func (s *microBranchStep) String() string {
+	return s.id("branch." + s.path)
+}
+
+func (s *microBranchStep) Name() string {
+	return s.String()
+}
+
+func (s *microBranchStep) Hashcode() interface{} {
+	return s.String()
+}
+
+func (s *microBranchStep) Type() string {
+	return "branch"
+}
+
+func (s *microBranchStep) MarshalStep() (*StepDefinition, error) {
+	def := newStepDefinition(s.Type(), s.ID(), s.opts)
+	def.Path = s.path
+	def.Branches = s.cases
+	return def, nil
+}
+
+func (s *microBranchStep) UnmarshalStep(def *StepDefinition) (Step, error) {
+	opts, err := def.stepOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &microBranchStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, path: def.Path, cases: def.Branches}, nil
+}
+
+// branches returns ids of all steps selected by branch step
+func (s *microBranchStep) branches() []string {
+	ids := make([]string, 0, len(s.cases))
+	for _, id := range s.cases {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// branch returns id of the step selected by value in message
+func (s *microBranchStep) branch(msg *Message) (string, error) {
+	body, err := decodeBody(msg.Body)
+	if err != nil {
+		return "", err
+	}
+	val, err := rutil.Lookup(body, s.path)
+	if err == nil && val.IsValid() && val.CanInterface() {
+		if id, ok := s.cases[fmt.Sprint(val.Interface())]; ok {
+			return id, nil
+		}
+	}
+	if id, ok := s.cases[BranchDefault]; ok {
+		return id, nil
+	}
+	return "", ErrBranchNotFound
+}
+
+func (s *microBranchStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
+	if _, err := s.branch(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// NewBranchStep create new step that selects which of dependent steps executed.
+// Value found in the request body by util/reflect.Lookup path compared with cases keys,
+// dependent step with id from matched case executed and steps from other cases skipped.
+// Case with BranchDefault key used if no other case matched.
+func NewBranchStep(path string, cases map[string]string, opts ...StepOption) Step {
+	return &microBranchStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, path: path, cases: cases}
+}
+
+type microWorkflowStep struct {
+	baseStep
+	workflow string
+}
+
+func (s *microWorkflowStep) ID() string {
+	return s.String()
+}
+
+func (s *microWorkflowStep) Endpoint() string {
+	return s.workflow
+}
+
+func (s *microWorkflowStep) String() string {
+	return s.id("workflow." + s.workflow)
+}
+
+func (s *microWorkflowStep) Name() string {
+	return s.String()
+}
+
+func (s *microWorkflowStep) Hashcode() interface{} {
+	return s.String()
+}
+
+func (s *microWorkflowStep) Type() string {
+	return "workflow"
+}
+
+func (s *microWorkflowStep) MarshalStep() (*StepDefinition, error) {
+	def := newStepDefinition(s.Type(), s.ID(), s.opts)
+	def.Endpoint = s.workflow
+	return def, nil
+}
+
+func (s *microWorkflowStep) UnmarshalStep(def *StepDefinition) (Step, error) {
+	opts, err := def.stepOptions()
+	if err != nil {
+		return nil, err
+	}
+	return &microWorkflowStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, workflow: def.Endpoint}, nil
+}
+
+func (s *microWorkflowStep) Execute(ctx context.Context, req *Message, opts ...ExecuteOption) (*Message, error) {
+	options := NewExecuteOptions(opts...)
+	if options.Store == nil {
+		return nil, ErrMissingStore
+	}
+	ctx, cancel := stepContext(ctx, s.opts, &options)
+	defer cancel()
+
+	f := NewFlow(
+		Store(options.Store),
+		Client(options.Client),
+		Logger(options.Logger),
+		Tracer(options.Tracer),
+		Meter(options.Meter),
+		EventTopic(options.EventTopic),
+		LeaseTTL(options.LeaseTTL),
+		Sync(options.Sync),
+	)
+
+	w, err := f.WorkflowLoad(ctx, s.workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	eid, err := w.Execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.(*microWorkflow).response(ctx, eid)
+}
+
+// NewWorkflowStep create new step that loads stored workflow and executes it with step request.
+// Response of the workflow final step used as step response, for multiple final steps
+// response is JSON object with their responses keyed by step id.
+func NewWorkflowStep(workflow string, opts ...StepOption) Step {
+	return &microWorkflowStep{baseStep: baseStep{opts: NewStepOptions(opts...)}, workflow: workflow}
+}
+
+// response returns response of the workflow execution built from final steps responses
+func (w *microWorkflow) response(ctx context.Context, eid string) (*Message, error) {
+	sep := w.opts.Store.Options().Separator
+	stepStore := store.NewNamespaceStore(w.opts.Store, "steps"+sep+eid)
+
+	w.RLock()
+	handlers := handlerSteps(w.steps)
+	required := make(map[string]struct{}, len(w.steps))
+	for _, step := range w.steps {
+		for _, id := range step.Requires() {
+			required[id] = struct{}{}
+		}
+	}
+	var ids []string
+	for id := range w.steps {
+		_, isHandler := handlers[id]
+		_, isRequired := required[id]
+		if !isHandler && !isRequired {
+			ids = append(ids, id)
+		}
+	}
+	w.RUnlock()
+	sort.Strings(ids)
+
+	rsps := make(map[string]*Message, len(ids))
+	for _, id := range ids {
+		rsp := &Message{}
+		if err := stepStore.Read(ctx, id+sep+"rsp", rsp); err == store.ErrNotFound {
+			// final step skipped by branch
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		rsps[id] = rsp
+	}
+
+	if len(rsps) == 1 {
+		for _, rsp := range rsps {
+			return rsp, nil
+		}
+	}
+
+	msg := &Message{Header: metadata.New(0)}
+	bodies := make(map[string]json.RawMessage, len(rsps))
+	for _, id := range ids {
+		if rsp, ok := rsps[id]; ok {
+			msg.Header = metadata.Merge(msg.Header, rsp.Header, true)
+			bodies[id] = json.RawMessage(rsp.Body)
+		}
+	}
+
+	var err error
+	msg.Body, err = json.Marshal(bodies)
+	return msg, err
+}
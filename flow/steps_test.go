@@ -0,0 +1,271 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/broker"
+	"go.unistack.org/micro/v3/client"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/store"
+)
+
+func newTestClient(t *testing.T) (client.Client, broker.Broker) {
+	b := broker.NewBroker()
+	if err := b.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return client.NewClient(client.Broker(b)), b
+}
+
+func TestPublishWaitStep(t *testing.T) {
+	ctx := context.Background()
+	c, b := newTestClient(t)
+
+	msgs := make(chan *broker.Message, 1)
+	sub, err := b.Subscribe(ctx, "events", func(evt broker.Event) error {
+		msgs <- evt.Message()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe(ctx)
+
+	pub := NewPublishStep("events")
+	if _, err = pub.Execute(ctx, &Message{Body: RawMessage(`{"id":"1"}`)}, ExecuteClient(c)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgs:
+		if string(msg.Body) != `{"id":"1"}` {
+			t.Fatalf("invalid message %s", msg.Body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not published")
+	}
+
+	wait := NewWaitStep("replies", "$.id")
+	rsps := make(chan *Message, 1)
+	errs := make(chan error, 1)
+	go func() {
+		rsp, werr := wait.Execute(ctx, &Message{Body: RawMessage(`{"id":"1"}`)}, ExecuteClient(c), ExecuteTimeout(time.Second))
+		if werr != nil {
+			errs <- werr
+			return
+		}
+		rsps <- rsp
+	}()
+
+	for {
+		for _, cid := range []string{"2", "1"} {
+			msg := &broker.Message{Header: metadata.New(1), Body: broker.RawMessage(`{"reply":"` + cid + `"}`)}
+			msg.Header.Set(metadata.HeaderCorrelationID, cid)
+			if err = b.Publish(ctx, "replies", msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		select {
+		case rsp := <-rsps:
+			if string(rsp.Body) != `{"reply":"1"}` {
+				t.Fatalf("invalid response %s", rsp.Body)
+			}
+			return
+		case werr := <-errs:
+			t.Fatal(werr)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestWorkflowWaitEarlyReply(t *testing.T) {
+	ctx := context.Background()
+	c, b := newTestClient(t)
+
+	// reply published before wait step executed
+	sub, err := b.Subscribe(ctx, "orders", func(evt broker.Event) error {
+		msg := &broker.Message{Header: metadata.New(1), Body: broker.RawMessage(`{"reply":"1"}`)}
+		msg.Header.Set(metadata.HeaderCorrelationID, "1")
+		return b.Publish(ctx, "replies", msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe(ctx)
+
+	f := NewFlow(Store(store.NewStore()), Client(c))
+	w, err := f.WorkflowCreate(ctx, "workflow",
+		NewPublishStep("orders", StepID("publish")),
+		NewWaitStep("replies", "$.id", StepID("wait"), StepRequires("publish"), StepTimeout(time.Second)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{"id":"1"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := w.(*microWorkflow).response(ctx, eid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rsp.Body) != `{"reply":"1"}` {
+		t.Fatalf("invalid response %s", rsp.Body)
+	}
+}
+
+func TestDelayStep(t *testing.T) {
+	step := NewDelayStep(20 * time.Millisecond)
+
+	start := time.Now()
+	rsp, err := step.Execute(context.Background(), &Message{Body: RawMessage(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatal("delay step returns too early")
+	}
+	if string(rsp.Body) != `{}` {
+		t.Fatalf("invalid response %s", rsp.Body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = NewDelayStep(time.Minute).Execute(ctx, &Message{}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// step timeout applied when step executed outside of workflow
+	if _, err = NewDelayStep(time.Minute, StepTimeout(10*time.Millisecond)).Execute(context.Background(), &Message{}); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestBranchStep(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	root := &testStep{id: "root"}
+	branch := NewBranchStep("$.kind", map[string]string{"a": "a", BranchDefault: "b"}, StepID("branch"), StepRequires("root"))
+	a := &testStep{id: "a"}
+	a.opts.Requires = []string{"branch"}
+	b := &testStep{id: "b"}
+	b.opts.Requires = []string{"branch"}
+	join := &testStep{id: "join"}
+	join.opts.Requires = []string{"a", "b"}
+	after := &testStep{id: "after_b"}
+	after.opts.Requires = []string{"b"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", root, branch, a, b, join, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eid, err := w.Execute(ctx, &Message{Body: RawMessage(`{"kind":"a"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.count != 1 || b.count != 0 || join.count != 1 || after.count != 0 {
+		t.Fatalf("invalid executions count a %d b %d join %d after_b %d", a.count, b.count, join.count, after.count)
+	}
+	if status := stepStatus(t, f, eid, "b"); status != StatusSkipped {
+		t.Fatalf("invalid status %s", status)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{"kind":"c"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if a.count != 1 || b.count != 1 || after.count != 1 {
+		t.Fatalf("invalid executions count a %d b %d after_b %d", a.count, b.count, after.count)
+	}
+}
+
+func TestWorkflowStep(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	s := store.NewStore()
+	f := NewFlow(Store(s), Client(c), EventTopic("flow.events"))
+
+	events := make(chan *Event, 64)
+	esub, err := f.(ExecutionHistory).EventSubscribe(ctx, func(evt *Event) error {
+		events <- evt
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer esub.Unsubscribe(ctx)
+
+	sub, err := f.WorkflowCreate(ctx, "sub", NewDelayStep(time.Millisecond, StepID("first")), NewDelayStep(time.Millisecond, StepID("second"), StepRequires("first")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.WorkflowSave(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	root := &testStep{id: "root", body: `{"id":1}`}
+	step := NewWorkflowStep("sub", StepID("sub"), StepRequires("root"))
+	last := &testStep{id: "last"}
+	last.opts.Requires = []string{"sub"}
+
+	w, err := f.WorkflowCreate(ctx, "workflow", root, step, last)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Execute(ctx, &Message{Body: RawMessage(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if v := string(last.Request().Body); v != `{"id":1}` {
+		t.Fatalf("invalid request %s", v)
+	}
+
+	for {
+		select {
+		case evt := <-events:
+			if evt.Workflow == "sub" {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("sub workflow event not received")
+		}
+	}
+}
+
+func TestStepsParallelExecutions(t *testing.T) {
+	ctx := context.Background()
+	f := NewFlow(Store(store.NewStore()))
+
+	w, err := f.WorkflowCreate(ctx, "workflow",
+		NewDelayStep(time.Millisecond, StepID("delay")),
+		NewBranchStep("$.kind", map[string]string{BranchDefault: "last"}, StepID("branch"), StepRequires("delay")),
+		NewDelayStep(time.Millisecond, StepID("last"), StepRequires("branch")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := w.Execute(ctx, &Message{Body: RawMessage(fmt.Sprintf(`{"kind":"%d"}`, i))})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package flow
+
+import (
+	"context"
+	"sync"
+
+	"go.unistack.org/micro/v3/broker"
+	"go.unistack.org/micro/v3/metadata"
+)
+
+type waitBuffersKey struct{}
+
+// waitBuffer holds first message received on the wait step topic for each correlation id,
+// so reply published before wait step executed not lost
+type waitBuffer struct {
+	msgs   map[string]*broker.Message
+	notify chan struct{}
+	sub    broker.Subscriber
+	sync.Mutex
+}
+
+func newWaitBuffer(ctx context.Context, b broker.Broker, topic string) (*waitBuffer, error) {
+	wb := &waitBuffer{msgs: make(map[string]*broker.Message), notify: make(chan struct{})}
+	sub, err := b.Subscribe(ctx, topic, func(evt broker.Event) error {
+		msg := evt.Message()
+		cid, ok := msg.Header.Get(metadata.HeaderCorrelationID)
+		if !ok || cid == "" {
+			return nil
+		}
+		wb.Lock()
+		if _, ok = wb.msgs[cid]; !ok {
+			wb.msgs[cid] = msg
+			close(wb.notify)
+			wb.notify = make(chan struct{})
+		}
+		wb.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	wb.sub = sub
+	return wb, nil
+}
+
+// wait returns message with correlation id received since buffer created
+func (wb *waitBuffer) wait(ctx context.Context, cid string) (*broker.Message, error) {
+	for {
+		wb.Lock()
+		msg, ok := wb.msgs[cid]
+		notify := wb.notify
+		wb.Unlock()
+		if ok {
+			return msg, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+func (wb *waitBuffer) stop() {
+	_ = wb.sub.Unsubscribe(context.Background())
+}
+
+// subscribeWaits subscribes wait steps of the execution before any step executed,
+// returned context passes buffers to wait steps and returned func unsubscribes them
+func subscribeWaits(ctx context.Context, steps map[string]Step, options ExecuteOptions) (context.Context, func(), error) {
+	waits := make(map[string]*waitBuffer)
+	stop := func() {
+		for _, wb := range waits {
+			wb.stop()
+		}
+	}
+
+	for id, step := range steps {
+		wstep, ok := step.(*microWaitStep)
+		if !ok || options.Client == nil {
+			continue
+		}
+		wb, err := newWaitBuffer(ctx, options.Client.Options().Broker, wstep.topic)
+		if err != nil {
+			stop()
+			return ctx, nil, err
+		}
+		waits[id] = wb
+	}
+
+	if len(waits) == 0 {
+		return ctx, stop, nil
+	}
+	return context.WithValue(ctx, waitBuffersKey{}, waits), stop, nil
+}
+
+// waitBufferFromContext returns buffer of the wait step subscribed when execution started
+func waitBufferFromContext(ctx context.Context, id string) (*waitBuffer, bool) {
+	waits, ok := ctx.Value(waitBuffersKey{}).(map[string]*waitBuffer)
+	if !ok {
+		return nil, false
+	}
+	wb, ok := waits[id]
+	return wb, ok
+}
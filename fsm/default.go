@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 type state struct {
@@ -11,7 +12,13 @@ type state struct {
 	name string
 }
 
-var _ State = &state{}
+var (
+	_ State         = &state{}
+	_ FSM           = &fsm{}
+	_ EventFSM      = &fsm{}
+	_ PersistentFSM = &fsm{}
+	_ Inspector     = &fsm{}
+)
 
 func (s *state) Name() string {
 	return s.name
@@ -24,10 +31,17 @@ func (s *state) Body() interface{} {
 // fsm is a finite state machine
 type fsm struct {
 	statesMap   map[string]StateFunc
+	body        interface{}
 	current     string
 	statesOrder []string
 	opts        Options
+	entered     time.Time
+	history     []HistoryEntry
+	timers      map[string]*stateTimer
+	version     int64
 	mu          sync.Mutex
+	// serializes declarative runs
+	fire sync.Mutex
 }
 
 // NewFSM creates a new finite state machine having the specified initial state
@@ -39,7 +53,7 @@ func NewFSM(opts ...Option) *fsm {
 	}
 }
 
-// Current returns the current state
+// Current returns the current state, states active in parallel regions joined by comma
 func (f *fsm) Current() string {
 	f.mu.Lock()
 	s := f.current
@@ -51,6 +65,9 @@ func (f *fsm) Current() string {
 func (f *fsm) Reset() {
 	f.mu.Lock()
 	f.current = f.opts.Initial
+	f.body = nil
+	f.resetHistory()
+	f.stopTimers()
 	f.mu.Unlock()
 }
 
@@ -64,48 +81,142 @@ func (f *fsm) State(state string, fn StateFunc) {
 
 // Start runs state machine with provided data
 func (f *fsm) Start(ctx context.Context, args interface{}, opts ...Option) (interface{}, error) {
-	var err error
-
 	f.mu.Lock()
 	options := f.opts
+	options.Enter = copyHooks(f.opts.Enter)
+	options.Exit = copyHooks(f.opts.Exit)
 
 	for _, opt := range opts {
 		opt(&options)
 	}
 
-	sopts := []StateOption{StateDryRun(options.DryRun)}
+	if len(options.Transitions) == 0 {
+		if err := checkTimeouts(options); err != nil {
+			f.mu.Unlock()
+			return nil, err
+		}
+	}
 
-	cstate := options.Initial
 	states := make(map[string]StateFunc, len(f.statesMap))
 	for k, v := range f.statesMap {
 		states[k] = v
 	}
-	f.current = cstate
+	f.current = options.Initial
+	f.body = args
+	f.resetHistory()
+	f.stopTimers()
 	f.mu.Unlock()
 
-	var s State
-	s = &state{name: cstate, body: args}
-	nstate := s.Name()
+	// new run replaces persisted instance
+	if err := f.syncVersion(ctx, options); err != nil {
+		return nil, err
+	}
+
+	if len(options.Transitions) > 0 {
+		f.fire.Lock()
+		defer f.fire.Unlock()
+		return f.dispatch(ctx, args, []pending{{to: options.Initial}}, states, options)
+	}
+
+	return f.run(ctx, &state{name: options.Initial, body: args}, options.Initial, states, options)
+}
+
+// Fire finds transitions declared for active states or their parents and event, checks their guards,
+// moves state machine to transitions targets and runs state funcs of entered states
+func (f *fsm) Fire(ctx context.Context, event string) (interface{}, error) {
+	f.fire.Lock()
+	defer f.fire.Unlock()
 
+	f.mu.Lock()
+	options := f.opts
+	current, body := f.current, f.body
+	states := make(map[string]StateFunc, len(f.statesMap))
+	for k, v := range f.statesMap {
+		states[k] = v
+	}
+	f.mu.Unlock()
+
+	if current == "" {
+		return nil, ErrNotStarted
+	}
+
+	// each parallel region handles event independently
+	var queue []pending
+	var err error
+	seen := make(map[string]bool)
+	for _, leaf := range activeStates(current) {
+		t, from, terr := findTransition(ctx, body, leaf, func(t Transition) bool { return t.Event == event }, options.Transitions)
+		if terr != nil {
+			if err == nil || terr == ErrTransitionRejected {
+				err = terr
+			}
+			continue
+		}
+		if !seen[from] {
+			seen[from] = true
+			queue = append(queue, pending{from: from, to: t.To, event: event})
+		}
+	}
+
+	if len(queue) == 0 {
+		return body, fmt.Errorf(`event "%s" in state "%s" %w`, event, current, err)
+	}
+
+	return f.dispatch(ctx, body, queue, states, options)
+}
+
+// run executes state funcs starting from nstate,
+// state machine persisted after each transition and after run finished
+func (f *fsm) run(ctx context.Context, s State, nstate string, states map[string]StateFunc, options Options) (rsp interface{}, err error) {
+	defer func() {
+		if perr := f.persist(ctx, options); perr != nil && err == nil {
+			err = perr
+		}
+		if err != nil {
+			f.recordError(err)
+		}
+	}()
+
+	sopts := []StateOption{StateDryRun(options.DryRun)}
+
+	var prev string
 	for {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
 			fn, ok := states[nstate]
+			f.record(prev, nstate, "")
+			prev = nstate
 			if !ok {
 				return nil, fmt.Errorf(`state "%s" %w`, nstate, ErrInvalidState)
 			}
 			f.mu.Lock()
 			f.current = nstate
 			f.mu.Unlock()
+			if err = f.persist(ctx, options); err != nil {
+				return s.Body(), err
+			}
 
 			// wrap the handler func
 			for i := len(options.Wrappers); i > 0; i-- {
 				fn = options.Wrappers[i-1](fn)
 			}
 
-			s, err = fn(ctx, s, sopts...)
+			body := s.Body()
+			sctx, cancel, fopts := stateContext(ctx, nstate, options, sopts)
+			s, err = fn(sctx, s, fopts...)
+			// state func result discarded when state timed out
+			if sctx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				s, err = &state{name: options.Timeouts[nstate].To, body: body}, nil
+			}
+			cancel()
+			if s == nil {
+				s = &state{name: nstate, body: body}
+			}
+			f.mu.Lock()
+			f.body = s.Body()
+			f.mu.Unlock()
 
 			switch {
 			case err != nil:
@@ -124,3 +235,23 @@ func (f *fsm) Start(ctx context.Context, args interface{}, opts ...Option) (inte
 		}
 	}
 }
+
+func checkGuards(ctx context.Context, s State, event string, guards []GuardFunc) bool {
+	for _, fn := range guards {
+		if !fn(ctx, s, event) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyHooks(hooks map[string][]HookFunc) map[string][]HookFunc {
+	if hooks == nil {
+		return nil
+	}
+	nhooks := make(map[string][]HookFunc, len(hooks))
+	for k, v := range hooks {
+		nhooks[k] = append([]HookFunc{}, v...)
+	}
+	return nhooks
+}
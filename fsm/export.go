@@ -0,0 +1,267 @@
+package fsm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// States returns sorted names of registered and declared states including parents of hierarchical states
+func (f *fsm) States() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return collectStates(f.statesOrder, f.opts)
+}
+
+// DOT returns state graph in Graphviz DOT format, hierarchical and parallel states drawn as clusters.
+// State machine without transitions drawn with edges between states in order of registration
+func (f *fsm) DOT() []byte {
+	f.mu.Lock()
+	options := f.opts
+	states := collectStates(f.statesOrder, options)
+	transitions := exportTransitions(f.statesOrder, options)
+	f.mu.Unlock()
+
+	name := options.ID
+	if name == "" {
+		name = "fsm"
+	}
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "digraph %s {\n", strconv.Quote(name))
+	buf.WriteString("\tcompound=true;\n")
+	if options.Initial != "" {
+		buf.WriteString("\t\"__start\" [shape=point];\n")
+		fmt.Fprintf(buf, "\t\"__start\" -> %s%s;\n", strconv.Quote(leafState(options.Initial, states, options)), dotCluster("lhead", options.Initial, states))
+	}
+
+	for _, name := range childStates("", states) {
+		writeDOTState(buf, name, states, options, 1)
+	}
+
+	for _, t := range expandTransitions(transitions, states) {
+		var attrs []string
+		if t.Event != "" {
+			attrs = append(attrs, "label="+strconv.Quote(t.Event))
+		}
+		if len(childStates(t.From, states)) > 0 {
+			attrs = append(attrs, "ltail="+strconv.Quote("cluster_"+t.From))
+		}
+		if len(childStates(t.To, states)) > 0 {
+			attrs = append(attrs, "lhead="+strconv.Quote("cluster_"+t.To))
+		}
+		fmt.Fprintf(buf, "\t%s -> %s", strconv.Quote(leafState(t.From, states, options)), strconv.Quote(leafState(t.To, states, options)))
+		if len(attrs) > 0 {
+			fmt.Fprintf(buf, " [%s]", strings.Join(attrs, ", "))
+		}
+		buf.WriteString(";\n")
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// Mermaid returns state graph as Mermaid state diagram, StateEnd drawn as final state.
+// State machine without transitions drawn with edges between states in order of registration
+func (f *fsm) Mermaid() []byte {
+	f.mu.Lock()
+	options := f.opts
+	states := collectStates(f.statesOrder, options)
+	transitions := exportTransitions(f.statesOrder, options)
+	f.mu.Unlock()
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("stateDiagram-v2\n")
+	if options.Initial != "" {
+		fmt.Fprintf(buf, "\t[*] --> %s\n", mermaidID(options.Initial))
+	}
+
+	for _, name := range childStates("", states) {
+		writeMermaidState(buf, name, states, options, 1)
+	}
+
+	for _, t := range expandTransitions(transitions, states) {
+		fmt.Fprintf(buf, "\t%s --> %s", mermaidID(t.From), mermaidID(t.To))
+		if t.Event != "" {
+			fmt.Fprintf(buf, " : %s", t.Event)
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+func writeDOTState(buf *bytes.Buffer, name string, states []string, options Options, depth int) {
+	indent := strings.Repeat("\t", depth)
+	children := childStates(name, states)
+	if len(children) == 0 {
+		shape := "box"
+		if name == StateEnd {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(buf, "%s%s [label=%s, shape=%s];\n", indent, strconv.Quote(name), strconv.Quote(stateLabel(name)), shape)
+		return
+	}
+
+	fmt.Fprintf(buf, "%ssubgraph %s {\n", indent, strconv.Quote("cluster_"+name))
+	fmt.Fprintf(buf, "%s\tlabel=%s;\n", indent, strconv.Quote(stateLabel(name)))
+	if _, ok := options.Parallel[parentState(name)]; ok {
+		// regions of parallel state
+		fmt.Fprintf(buf, "%s\tstyle=dashed;\n", indent)
+	}
+	for _, child := range children {
+		writeDOTState(buf, child, states, options, depth+1)
+	}
+	fmt.Fprintf(buf, "%s}\n", indent)
+}
+
+func writeMermaidState(buf *bytes.Buffer, name string, states []string, options Options, depth int) {
+	if name == StateEnd {
+		return
+	}
+
+	indent := strings.Repeat("\t", depth)
+	fmt.Fprintf(buf, "%sstate %s as %s\n", indent, strconv.Quote(stateLabel(name)), mermaidID(name))
+
+	children := childStates(name, states)
+	if len(children) == 0 {
+		return
+	}
+
+	fmt.Fprintf(buf, "%sstate %s {\n", indent, mermaidID(name))
+	if regions := options.Parallel[name]; len(regions) > 0 {
+		for idx, region := range regions {
+			if idx > 0 {
+				fmt.Fprintf(buf, "%s\t--\n", indent)
+			}
+			writeMermaidState(buf, region, states, options, depth+1)
+		}
+	} else {
+		if child, ok := options.Substates[name]; ok {
+			fmt.Fprintf(buf, "%s\t[*] --> %s\n", indent, mermaidID(child))
+		}
+		for _, child := range children {
+			writeMermaidState(buf, child, states, options, depth+1)
+		}
+	}
+	fmt.Fprintf(buf, "%s}\n", indent)
+}
+
+// collectStates returns sorted names of states from state funcs, transitions, substates and parallel regions
+func collectStates(names []string, options Options) []string {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		for ; name != "" && name != StateAny && !seen[name]; name = parentState(name) {
+			seen[name] = true
+		}
+	}
+
+	add(options.Initial)
+	for _, name := range names {
+		add(name)
+	}
+	for _, t := range options.Transitions {
+		add(t.From)
+		add(t.To)
+	}
+	for parent, child := range options.Substates {
+		add(parent)
+		add(child)
+	}
+	for name, regions := range options.Parallel {
+		add(name)
+		for _, region := range regions {
+			add(region)
+		}
+	}
+
+	states := make([]string, 0, len(seen))
+	for name := range seen {
+		states = append(states, name)
+	}
+	sort.Strings(states)
+
+	return states
+}
+
+// childStates returns direct substates of parent, top level states for empty parent
+func childStates(parent string, states []string) []string {
+	var children []string
+	for _, name := range states {
+		if parentState(name) == parent {
+			children = append(children, name)
+		}
+	}
+	return children
+}
+
+// exportTransitions returns declared transitions, state machine without them moves to the next registered state
+// when state func returns empty state name, so edges drawn between consecutive registered states
+func exportTransitions(names []string, options Options) []Transition {
+	if len(options.Transitions) > 0 {
+		return options.Transitions
+	}
+	var ts []Transition
+	for idx := 1; idx < len(names); idx++ {
+		ts = append(ts, Transition{From: names[idx-1], To: names[idx]})
+	}
+	return ts
+}
+
+// expandTransitions replaces transitions from StateAny by transitions from each top level state
+func expandTransitions(transitions []Transition, states []string) []Transition {
+	var ts []Transition
+	for _, t := range transitions {
+		if t.From != StateAny {
+			ts = append(ts, t)
+			continue
+		}
+		for _, name := range childStates("", states) {
+			if name != StateEnd {
+				ts = append(ts, Transition{From: name, Event: t.Event, To: t.To})
+			}
+		}
+	}
+	return ts
+}
+
+// leafState returns state drawn for edges to or from hierarchical state, it is the state entered by default
+func leafState(name string, states []string, options Options) string {
+	if _, leaves := defaultEntry(name, options); len(leaves) > 0 && leaves[0] != name {
+		return leaves[0]
+	}
+	if children := childStates(name, states); len(children) > 0 {
+		return leafState(children[0], states, options)
+	}
+	return name
+}
+
+func dotCluster(attr string, name string, states []string) string {
+	if len(childStates(name, states)) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s=%s]", attr, strconv.Quote("cluster_"+name))
+}
+
+// stateLabel returns state name without parents
+func stateLabel(name string) string {
+	if idx := strings.LastIndex(name, StateSeparator); idx >= 0 {
+		return name[idx+len(StateSeparator):]
+	}
+	return name
+}
+
+// mermaidID returns state identifier allowed in Mermaid diagram
+func mermaidID(name string) string {
+	if name == StateEnd {
+		return "[*]"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,87 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newTestExportFSM() *fsm {
+	return NewFSM(
+		ID("charger"),
+		InitialState("idle"),
+		InitialSubstate("active", "active.charging"),
+		Transitions(
+			Transition{From: "idle", Event: "plug", To: "active"},
+			Transition{From: "active.charging", Event: "full", To: "active.done"},
+			Transition{From: "active", Event: "unplug", To: "idle"},
+			Transition{From: StateAny, Event: "shutdown", To: StateEnd},
+		),
+	)
+}
+
+func TestFSMStates(t *testing.T) {
+	f := newTestExportFSM()
+	if v := fmt.Sprint(f.States()); v != "[active active.charging active.done end idle]" {
+		t.Fatalf("invalid states %s", v)
+	}
+}
+
+func TestFSMDOT(t *testing.T) {
+	dot := string(newTestExportFSM().DOT())
+
+	for _, line := range []string{
+		`digraph "charger" {`,
+		`"__start" -> "idle";`,
+		`subgraph "cluster_active" {`,
+		`"active.done" [label="done", shape=box];`,
+		`"end" [label="end", shape=doublecircle];`,
+		`"idle" -> "active.charging" [label="plug", lhead="cluster_active"];`,
+		`"active.charging" -> "idle" [label="unplug", ltail="cluster_active"];`,
+		`"idle" -> "end" [label="shutdown"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Fatalf("dot has no %s\n%s", line, dot)
+		}
+	}
+}
+
+func TestFSMMermaid(t *testing.T) {
+	mermaid := string(newTestExportFSM().Mermaid())
+
+	for _, line := range []string{
+		"stateDiagram-v2\n",
+		"\t[*] --> idle\n",
+		"\tstate active {\n",
+		"\t\t[*] --> active_charging\n",
+		"\t\tstate \"done\" as active_done\n",
+		"\tidle --> active : plug\n",
+		"\tactive_charging --> active_done : full\n",
+		"\tactive --> [*] : shutdown\n",
+	} {
+		if !strings.Contains(mermaid, line) {
+			t.Fatalf("mermaid has no %q\n%s", line, mermaid)
+		}
+	}
+}
+
+func TestFSMExportFlat(t *testing.T) {
+	f := NewFSM(InitialState("first"))
+	for _, name := range []string{"first", "second", "third"} {
+		f.State(name, nil)
+	}
+
+	dot := string(f.DOT())
+	for _, line := range []string{`"__start" -> "first";`, `"first" -> "second";`, `"second" -> "third";`} {
+		if !strings.Contains(dot, line) {
+			t.Fatalf("dot has no %s\n%s", line, dot)
+		}
+	}
+
+	mermaid := string(f.Mermaid())
+	for _, line := range []string{"\t[*] --> first\n", "\tfirst --> second\n", "\tsecond --> third\n"} {
+		if !strings.Contains(mermaid, line) {
+			t.Fatalf("mermaid has no %q\n%s", line, mermaid)
+		}
+	}
+}
@@ -7,7 +7,17 @@ import (
 
 var (
 	ErrInvalidState = errors.New("does not exists")
-	StateEnd        = "end"
+	// ErrInvalidTransition returns when transition not declared in transitions table
+	ErrInvalidTransition = errors.New("transition not declared")
+	// ErrTransitionRejected returns when all declared transitions rejected by guards
+	ErrTransitionRejected = errors.New("transition rejected by guard")
+	// ErrTimeoutEvent returns when timeout event used without transitions table
+	ErrTimeoutEvent = errors.New("requires transitions")
+	// ErrNotStarted returns when event fired before state machine started
+	ErrNotStarted = errors.New("not started")
+	StateEnd      = "end"
+	// StateAny used as Transition.From matches any state
+	StateAny = "*"
 )
 
 type State interface {
@@ -21,9 +31,55 @@ type StateWrapper func(StateFunc) StateFunc
 // StateFunc called on state transition and return next step and error
 type StateFunc func(ctx context.Context, state State, opts ...StateOption) (State, error)
 
+// GuardFunc called before transition, transition allowed only if all guards return true
+type GuardFunc func(ctx context.Context, state State, event string) bool
+
+// HookFunc called on state enter or exit, error stops state machine
+type HookFunc func(ctx context.Context, state State) error
+
+// Transition describes allowed transition from state to state on event
+type Transition struct {
+	// From state name or StateAny
+	From string
+	// Event name
+	Event string
+	// To state name
+	To string
+	// Guards checked before transition
+	Guards []GuardFunc
+}
+
 type FSM interface {
 	Start(context.Context, interface{}, ...Option) (interface{}, error)
 	Current() string
 	Reset()
 	State(string, StateFunc)
 }
+
+// EventFSM is implemented by state machines that handle events in declarative mode,
+// check it by type assertion on FSM
+type EventFSM interface {
+	// Fire sends event to state machine started in declarative mode and returns body after transition
+	Fire(context.Context, string) (interface{}, error)
+}
+
+// PersistentFSM is implemented by state machines persisted in store, check it by type assertion on FSM
+type PersistentFSM interface {
+	// Restore loads persisted instance state and body from store
+	Restore(context.Context) error
+	// Resume runs state machine from the current state
+	Resume(context.Context) (interface{}, error)
+}
+
+// Inspector is implemented by state machines that expose their states and history,
+// check it by type assertion on FSM
+type Inspector interface {
+	// States returns names of all known states
+	States() []string
+	// History returns transitions made by the last run
+	History() []HistoryEntry
+	// DOT returns state graph in Graphviz DOT format
+	DOT() []byte
+	// Mermaid returns state graph as Mermaid state diagram
+	Mermaid() []byte
+}
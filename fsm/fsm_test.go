@@ -2,6 +2,7 @@ package fsm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -70,3 +71,96 @@ func TestFSMStart(t *testing.T) {
 		t.Fatalf("invalid rsp %#+v", args)
 	}
 }
+
+func TestFSMDeclarative(t *testing.T) {
+	ctx := context.TODO()
+
+	var hooks []string
+	hook := func(name string) HookFunc {
+		return func(_ context.Context, s State) error {
+			hooks = append(hooks, name+":"+s.Name())
+			return nil
+		}
+	}
+	paid := func(_ context.Context, s State, event string) bool {
+		return s.Body().(map[string]interface{})["amount"].(int) > 0
+	}
+
+	f := NewFSM(
+		InitialState("created"),
+		Transitions(
+			Transition{From: "created", Event: "pay", To: "paid", Guards: []GuardFunc{paid}},
+			Transition{From: "paid", Event: "ship", To: "shipped"},
+			Transition{From: StateAny, Event: "cancel", To: "cancelled"},
+		),
+		OnExit("created", hook("exit")),
+		OnEnter("paid", hook("enter")),
+	)
+	f.State("created", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: "", body: s.Body()}, nil
+	})
+	f.State("paid", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: "shipped", body: s.Body()}, nil
+	})
+	f.State("shipped", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: StateEnd, body: s.Body()}, nil
+	})
+
+	if _, err := f.Fire(ctx, "pay"); err != ErrNotStarted {
+		t.Fatalf("expected ErrNotStarted, got %v", err)
+	}
+
+	if _, err := f.Start(ctx, map[string]interface{}{"amount": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if f.Current() != "created" {
+		t.Fatalf("invalid state %s", f.Current())
+	}
+
+	if _, err := f.Fire(ctx, "ship"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := f.Fire(ctx, "pay"); !errors.Is(err, ErrTransitionRejected) {
+		t.Fatalf("expected ErrTransitionRejected, got %v", err)
+	}
+
+	if _, err := f.Start(ctx, map[string]interface{}{"amount": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fire(ctx, "pay"); err != nil {
+		t.Fatal(err)
+	}
+	if f.Current() != StateEnd {
+		t.Fatalf("invalid state %s", f.Current())
+	}
+	if v := fmt.Sprint(hooks); v != "[exit:created enter:paid]" {
+		t.Fatalf("invalid hooks %s", v)
+	}
+
+	if _, err := f.Start(ctx, map[string]interface{}{"amount": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fire(ctx, "cancel"); err != nil {
+		t.Fatal(err)
+	}
+	if f.Current() != "cancelled" {
+		t.Fatalf("invalid state %s", f.Current())
+	}
+}
+
+func TestFSMUndeclaredTransition(t *testing.T) {
+	f := NewFSM(InitialState("1"), Transitions(Transition{From: "1", Event: "next", To: "2"}))
+	f.State("1", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: "3", body: s.Body()}, nil
+	})
+	f.State("3", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: StateEnd, body: s.Body()}, nil
+	})
+
+	if _, err := f.Start(context.TODO(), nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+	if f.Current() != "1" {
+		t.Fatalf("invalid state %s", f.Current())
+	}
+}
@@ -0,0 +1,298 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StateSeparator separates parent and child in hierarchical state names like active.charging
+const StateSeparator = "."
+
+// pending holds transition waiting for execution, resume transition runs state func without entering state
+type pending struct {
+	from   string
+	to     string
+	event  string
+	resume bool
+}
+
+// dispatch executes pending transitions and state funcs of entered states in declarative mode,
+// state func result is the next transition from its state, empty name means wait for event
+func (f *fsm) dispatch(ctx context.Context, body interface{}, queue []pending, states map[string]StateFunc, options Options) (rsp interface{}, err error) {
+	defer func() {
+		if perr := f.persist(ctx, options); perr != nil && err == nil {
+			err = perr
+		}
+		if err != nil {
+			f.recordError(err)
+		}
+	}()
+
+	for len(queue) > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		p := queue[0]
+		queue = queue[1:]
+
+		entered := []string{p.to}
+		if !p.resume {
+			// source state exited by previous transition
+			if p.from != "" && !f.isActive(p.from) {
+				continue
+			}
+			if entered, err = f.transit(ctx, body, p.from, p.to, p.event, options); err != nil {
+				return body, err
+			}
+		}
+
+		for _, name := range entered {
+			fn, ok := states[name]
+			if !ok || name == StateEnd {
+				continue
+			}
+
+			// wrap the handler func
+			for i := len(options.Wrappers); i > 0; i-- {
+				fn = options.Wrappers[i-1](fn)
+			}
+
+			sopts := []StateOption{StateDryRun(options.DryRun)}
+			f.mu.Lock()
+			if deadline, ok := f.deadline(name); ok {
+				sopts = append(sopts, StateDeadline(deadline))
+			}
+			f.mu.Unlock()
+
+			s, serr := fn(ctx, &state{name: name, body: body}, sopts...)
+			if s != nil {
+				body = s.Body()
+				f.mu.Lock()
+				f.body = body
+				f.mu.Unlock()
+			}
+			if serr != nil {
+				return body, serr
+			}
+			if s == nil || s.Name() == "" {
+				continue
+			}
+
+			var t Transition
+			if s.Name() != StateEnd {
+				to := s.Name()
+				if t, _, err = findTransition(ctx, body, name, func(t Transition) bool { return t.To == to }, options.Transitions); err != nil {
+					f.record(name, to, "")
+					return body, fmt.Errorf(`transition "%s" -> "%s" %w`, name, to, err)
+				}
+			}
+			queue = append(queue, pending{from: name, to: s.Name(), event: t.Event})
+		}
+	}
+
+	return body, nil
+}
+
+// transit exits active states below the common parent of from and to, innermost first,
+// and enters states from the common parent down to the target and its initial substates, outermost first.
+// Returns entered states.
+func (f *fsm) transit(ctx context.Context, body interface{}, from string, to string, event string, options Options) ([]string, error) {
+	var active []string
+	domain := ""
+	if from != "" {
+		f.mu.Lock()
+		active = activeStates(f.current)
+		f.mu.Unlock()
+
+		// transition to itself or to child state exits source state
+		domain = commonState(from, to)
+		if domain == from || domain == to {
+			domain = parentState(domain)
+		}
+	}
+
+	var exits, remaining []string
+	seen := make(map[string]bool)
+	for _, leaf := range active {
+		if domain != "" && !strings.HasPrefix(leaf, domain+StateSeparator) {
+			remaining = append(remaining, leaf)
+			continue
+		}
+		for name := leaf; name != domain && name != ""; name = parentState(name) {
+			if !seen[name] {
+				seen[name] = true
+				exits = append(exits, name)
+			}
+		}
+	}
+	sort.SliceStable(exits, func(i, j int) bool { return stateDepth(exits[i]) > stateDepth(exits[j]) })
+
+	for _, name := range exits {
+		for _, fn := range options.Exit[name] {
+			if err := fn(ctx, &state{name: name, body: body}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var path []string
+	for name := to; name != domain && name != ""; name = parentState(name) {
+		path = append([]string{name}, path...)
+	}
+
+	var entered, leaves []string
+	for idx, name := range path {
+		entered = append(entered, name)
+		if idx == len(path)-1 {
+			e, l := defaultEntry(name, options)
+			entered = append(entered, e...)
+			leaves = append(leaves, l...)
+			break
+		}
+		// other parallel regions entered in their initial states
+		for _, region := range options.Parallel[name] {
+			if region == path[idx+1] {
+				continue
+			}
+			e, l := defaultEntry(region, options)
+			entered = append(append(entered, region), e...)
+			leaves = append(leaves, l...)
+		}
+	}
+
+	current := append(remaining, leaves...)
+	sort.Strings(current)
+
+	f.record(from, to, event)
+
+	now := time.Now()
+	f.mu.Lock()
+	f.current = strings.Join(current, ",")
+	f.body = body
+	// timers of exited states stopped, timers of entered states restarted
+	for _, name := range exits {
+		f.stopTimer(name)
+	}
+	for _, name := range entered {
+		if t, ok := options.Timeouts[name]; ok {
+			f.startTimer(name, now.Add(t.After), options)
+		}
+	}
+	f.mu.Unlock()
+
+	if err := f.persist(ctx, options); err != nil {
+		return nil, err
+	}
+
+	for _, name := range entered {
+		for _, fn := range options.Enter[name] {
+			if err := fn(ctx, &state{name: name, body: body}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return entered, nil
+}
+
+// defaultEntry returns states entered by default when entering state and resulting active leaf states
+func defaultEntry(name string, options Options) ([]string, []string) {
+	if regions := options.Parallel[name]; len(regions) > 0 {
+		var entered, leaves []string
+		for _, region := range regions {
+			e, l := defaultEntry(region, options)
+			entered = append(append(entered, region), e...)
+			leaves = append(leaves, l...)
+		}
+		return entered, leaves
+	}
+	if child, ok := options.Substates[name]; ok {
+		e, l := defaultEntry(child, options)
+		return append([]string{child}, e...), l
+	}
+	return nil, []string{name}
+}
+
+// findTransition returns first matched transition which guards passed, transitions declared
+// for state checked first, then for its parents and then for StateAny.
+// Returned source is the state transition declared for or state itself for StateAny.
+func findTransition(ctx context.Context, body interface{}, name string, match func(Transition) bool, transitions []Transition) (Transition, string, error) {
+	s := &state{name: name, body: body}
+	err := ErrInvalidTransition
+
+	for from := name; ; from = parentState(from) {
+		if from == "" {
+			from = StateAny
+		}
+		for _, t := range transitions {
+			if t.From != from || !match(t) {
+				continue
+			}
+			if !checkGuards(ctx, s, t.Event, t.Guards) {
+				err = ErrTransitionRejected
+				continue
+			}
+			if from == StateAny {
+				return t, name, nil
+			}
+			return t, from, nil
+		}
+		if from == StateAny {
+			return Transition{}, "", err
+		}
+	}
+}
+
+// isActive checks that state or any of its substates active
+func (f *fsm) isActive(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return stateActive(f.current, name)
+}
+
+// stateActive checks that state or any of its substates is in current states
+func stateActive(current string, name string) bool {
+	for _, leaf := range activeStates(current) {
+		if leaf == name || strings.HasPrefix(leaf, name+StateSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// activeStates returns active leaf states, more than one state active inside parallel state
+func activeStates(current string) []string {
+	if current == "" {
+		return nil
+	}
+	return strings.Split(current, ",")
+}
+
+// parentState returns parent state name or empty string for top level state
+func parentState(name string) string {
+	if idx := strings.LastIndex(name, StateSeparator); idx >= 0 {
+		return name[:idx]
+	}
+	return ""
+}
+
+// commonState returns the nearest common parent of states or state itself
+func commonState(a string, b string) string {
+	pa := strings.Split(a, StateSeparator)
+	pb := strings.Split(b, StateSeparator)
+	var idx int
+	for idx < len(pa) && idx < len(pb) && pa[idx] == pb[idx] {
+		idx++
+	}
+	return strings.Join(pa[:idx], StateSeparator)
+}
+
+func stateDepth(name string) int {
+	return strings.Count(name, StateSeparator)
+}
@@ -0,0 +1,100 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFSMHierarchical(t *testing.T) {
+	ctx := context.TODO()
+
+	var hooks []string
+	hook := func(prefix string) HookFunc {
+		return func(_ context.Context, s State) error {
+			hooks = append(hooks, prefix+":"+s.Name())
+			return nil
+		}
+	}
+
+	opts := []Option{
+		InitialState("idle"),
+		InitialSubstate("active", "active.idle"),
+		Transitions(
+			Transition{From: "idle", Event: "start", To: "active"},
+			Transition{From: "active.idle", Event: "charge", To: "active.charging"},
+			Transition{From: "active", Event: "stop", To: "idle"},
+		),
+	}
+	for _, name := range []string{"idle", "active", "active.idle", "active.charging"} {
+		opts = append(opts, OnEnter(name, hook("enter")), OnExit(name, hook("exit")))
+	}
+	f := NewFSM(opts...)
+
+	if _, err := f.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		event   string
+		current string
+		hooks   string
+	}{
+		{"start", "active.idle", "[exit:idle enter:active enter:active.idle]"},
+		{"charge", "active.charging", "[exit:active.idle enter:active.charging]"},
+		{"stop", "idle", "[exit:active.charging exit:active enter:idle]"},
+	} {
+		hooks = nil
+		if _, err := f.Fire(ctx, tc.event); err != nil {
+			t.Fatal(err)
+		}
+		if f.Current() != tc.current {
+			t.Fatalf("%s: invalid state %s", tc.event, f.Current())
+		}
+		if v := fmt.Sprint(hooks); v != tc.hooks {
+			t.Fatalf("%s: invalid hooks %s", tc.event, v)
+		}
+	}
+}
+
+func TestFSMParallel(t *testing.T) {
+	ctx := context.TODO()
+
+	f := NewFSM(
+		InitialState("off"),
+		ParallelState("on", "on.heater", "on.light"),
+		InitialSubstate("on.heater", "on.heater.off"),
+		InitialSubstate("on.light", "on.light.off"),
+		Transitions(
+			Transition{From: "off", Event: "power", To: "on"},
+			Transition{From: "on", Event: "power", To: "off"},
+			Transition{From: "on.heater.off", Event: "heat", To: "on.heater.high"},
+			Transition{From: "on.light.off", Event: "toggle", To: "on.light.lit"},
+			Transition{From: "on.heater.high", Event: "reset", To: "on.heater.off"},
+			Transition{From: "on.light.lit", Event: "reset", To: "on.light.off"},
+		),
+	)
+
+	if _, err := f.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		event   string
+		current string
+	}{
+		{"power", "on.heater.off,on.light.off"},
+		{"heat", "on.heater.high,on.light.off"},
+		{"toggle", "on.heater.high,on.light.lit"},
+		{"reset", "on.heater.off,on.light.off"},
+		{"toggle", "on.heater.off,on.light.lit"},
+		{"power", "off"},
+	} {
+		if _, err := f.Fire(ctx, tc.event); err != nil {
+			t.Fatal(err)
+		}
+		if f.Current() != tc.current {
+			t.Fatalf("%s: invalid state %s", tc.event, f.Current())
+		}
+	}
+}
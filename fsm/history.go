@@ -0,0 +1,59 @@
+package fsm
+
+import (
+	"time"
+)
+
+// HistoryEntry describes transition made by state machine during the run
+type HistoryEntry struct {
+	// Time when state entered
+	Time time.Time
+	// Error returned by state func, hooks or store after state entered
+	Error error
+	// From state name, empty for the first state of the run
+	From string
+	// To state name
+	To string
+	// Event of the declared transition, empty in legacy mode
+	Event string
+	// Duration spent in the previous state
+	Duration time.Duration
+}
+
+// History returns transitions made since the last Start, oldest first
+func (f *fsm) History() []HistoryEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]HistoryEntry{}, f.history...)
+}
+
+// record appends transition to the history
+func (f *fsm) record(from string, to string, event string) {
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e := HistoryEntry{Time: now, From: from, To: to, Event: event}
+	if from != "" && !f.entered.IsZero() {
+		e.Duration = now.Sub(f.entered)
+	}
+	f.entered = now
+	f.history = append(f.history, e)
+}
+
+// recordError sets error of the last transition if it was not set before
+func (f *fsm) recordError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if n := len(f.history); n > 0 && f.history[n-1].Error == nil {
+		f.history[n-1].Error = err
+	}
+}
+
+// resetHistory clears history before new run
+func (f *fsm) resetHistory() {
+	f.history = nil
+	f.entered = time.Time{}
+}
@@ -0,0 +1,97 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.unistack.org/micro/v3/meter"
+	"go.unistack.org/micro/v3/tracer"
+)
+
+func TestFSMHistory(t *testing.T) {
+	ctx := context.TODO()
+	errFailed := errors.New("failed")
+
+	f := NewFSM(InitialState("created"))
+	f.State("created", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: "paid", body: s.Body()}, nil
+	})
+	f.State("paid", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return s, errFailed
+	})
+
+	if _, err := f.Start(ctx, nil); err != errFailed {
+		t.Fatalf("expected error %v, got %v", errFailed, err)
+	}
+
+	history := f.History()
+	if len(history) != 2 {
+		t.Fatalf("invalid history %#v", history)
+	}
+	if history[0].From != "" || history[0].To != "created" || history[0].Error != nil {
+		t.Fatalf("invalid first entry %#v", history[0])
+	}
+	if history[1].From != "created" || history[1].To != "paid" || history[1].Error != errFailed {
+		t.Fatalf("invalid second entry %#v", history[1])
+	}
+	if history[1].Time.Before(history[0].Time) || history[1].Duration < 0 {
+		t.Fatalf("invalid second entry time %#v", history[1])
+	}
+
+	f.Reset()
+	if len(f.History()) != 0 {
+		t.Fatal("history must be cleared by reset")
+	}
+}
+
+func TestFSMHistoryDeclarative(t *testing.T) {
+	ctx := context.TODO()
+
+	f := NewFSM(
+		InitialState("idle"),
+		Transitions(
+			Transition{From: "idle", Event: "start", To: "running"},
+			Transition{From: "running", Event: "stop", To: "idle"},
+		),
+	)
+	if _, err := f.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []string{"start", "stop"} {
+		if _, err := f.Fire(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var path []string
+	for _, e := range f.History() {
+		path = append(path, e.From+"-"+e.Event+"->"+e.To)
+	}
+	if v := fmt.Sprint(path); v != "[-->idle idle-start->running running-stop->idle]" {
+		t.Fatalf("invalid history %s", v)
+	}
+}
+
+func TestFSMWrappers(t *testing.T) {
+	ctx := context.TODO()
+
+	var traced bool
+	f := NewFSM(
+		InitialState("check"),
+		WrapState(TracerWrapper(tracer.NewTracer())),
+		WrapState(MeterWrapper(meter.NewMeter())),
+	)
+	f.State("check", func(ctx context.Context, s State, _ ...StateOption) (State, error) {
+		_, traced = tracer.SpanFromContext(ctx)
+		return &state{name: StateEnd, body: s.Body()}, nil
+	})
+
+	if _, err := f.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !traced {
+		t.Fatal("state func must run inside span")
+	}
+}
@@ -1,11 +1,44 @@
 package fsm
 
+import (
+	"context"
+	"time"
+
+	"go.unistack.org/micro/v3/logger"
+	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/sync"
+)
+
 // Options struct holding fsm options
 type Options struct {
+	// Context used by transitions made on state timeouts, timers stopped when it done
+	Context context.Context
+	// Logger used to log errors of transitions made on state timeouts
+	Logger logger.Logger
+	// Store used to persist instance after each transition
+	Store store.Store
+	// Sync used to lock persisted instance while it version checked and written
+	Sync sync.Sync
+	// NewBody returns pointer used to decode body of restored instance
+	NewBody func() interface{}
 	// Initial state
 	Initial string
+	// ID of the persisted instance
+	ID string
 	// Wrappers runs before state
 	Wrappers []StateWrapper
+	// Transitions holds declared transitions, if not empty state machine runs in declarative mode
+	Transitions []Transition
+	// Enter holds hooks called after state entered
+	Enter map[string][]HookFunc
+	// Exit holds hooks called before state exited
+	Exit map[string][]HookFunc
+	// Substates holds initial substates of hierarchical states
+	Substates map[string]string
+	// Parallel holds regions of parallel states
+	Parallel map[string][]string
+	// Timeouts holds transitions made when state active too long
+	Timeouts map[string]Timeout
 	// DryRun mode
 	DryRun bool
 }
@@ -15,7 +48,9 @@ type Option func(*Options)
 
 // StateOptions holds state options
 type StateOptions struct {
-	DryRun bool
+	// Deadline of the state timeout, zero if state has no timeout
+	Deadline time.Time
+	DryRun   bool
 }
 
 // StateDryRun says that state executes in dry run mode
@@ -25,6 +60,13 @@ func StateDryRun(b bool) StateOption {
 	}
 }
 
+// StateDeadline passes deadline of the state timeout to state func
+func StateDeadline(t time.Time) StateOption {
+	return func(o *StateOptions) {
+		o.Deadline = t
+	}
+}
+
 // StateOption func signature
 type StateOption func(*StateOptions)
 
@@ -42,9 +84,131 @@ func WrapState(w StateWrapper) Option {
 	}
 }
 
+// Transitions declares allowed transitions and enables declarative mode.
+// In declarative mode state func can return only state declared as transition target from its state,
+// state func that returns empty name stops state machine until event fired via Fire.
+func Transitions(ts ...Transition) Option {
+	return func(o *Options) {
+		o.Transitions = append(o.Transitions, ts...)
+	}
+}
+
+// OnEnter adds hook called after state entered and before its state func
+func OnEnter(state string, fn HookFunc) Option {
+	return func(o *Options) {
+		if o.Enter == nil {
+			o.Enter = make(map[string][]HookFunc)
+		}
+		o.Enter[state] = append(o.Enter[state], fn)
+	}
+}
+
+// OnExit adds hook called before state exited
+func OnExit(state string, fn HookFunc) Option {
+	return func(o *Options) {
+		if o.Exit == nil {
+			o.Exit = make(map[string][]HookFunc)
+		}
+		o.Exit[state] = append(o.Exit[state], fn)
+	}
+}
+
+// Store sets the store used to persist state and body of instance with id set by ID option.
+// Persisted instance can be loaded by Restore after restart.
+func Store(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// Sync sets the sync.Sync used to lock persisted instance by id while its version checked and written,
+// without it version check protects from stale writes only within one process
+func Sync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
+// ID sets the persisted instance id
+func ID(id string) Option {
+	return func(o *Options) {
+		o.ID = id
+	}
+}
+
+// NewBody sets func that returns pointer used to decode body of restored instance,
+// by default body decoded to generic value
+func NewBody(fn func() interface{}) Option {
+	return func(o *Options) {
+		o.NewBody = fn
+	}
+}
+
+// InitialSubstate sets substate entered when transition targets parent state,
+// substate name must contain parent name like active.idle
+func InitialSubstate(parent string, child string) Option {
+	return func(o *Options) {
+		if o.Substates == nil {
+			o.Substates = make(map[string]string)
+		}
+		o.Substates[parent] = child
+	}
+}
+
+// ParallelState declares state with orthogonal regions that active at the same time
+// and handle events independently, region names must contain state name like on.heater
+func ParallelState(name string, regions ...string) Option {
+	return func(o *Options) {
+		if o.Parallel == nil {
+			o.Parallel = make(map[string][]string)
+		}
+		o.Parallel[name] = append(o.Parallel[name], regions...)
+	}
+}
+
+// TimeoutEvent fires event when state active longer than after, timer restarted when state entered again
+// and stopped when state exited. Event timeouts require Transitions, so Start without them returns ErrTimeoutEvent.
+func TimeoutEvent(state string, after time.Duration, event string) Option {
+	return func(o *Options) {
+		if o.Timeouts == nil {
+			o.Timeouts = make(map[string]Timeout)
+		}
+		o.Timeouts[state] = Timeout{After: after, Event: event}
+	}
+}
+
+// TimeoutState moves state machine to state to when state active longer than after,
+// transition to target state need not be declared. Without Transitions state func runs with context
+// expired after, its result discarded on timeout.
+func TimeoutState(state string, after time.Duration, to string) Option {
+	return func(o *Options) {
+		if o.Timeouts == nil {
+			o.Timeouts = make(map[string]Timeout)
+		}
+		o.Timeouts[state] = Timeout{After: after, To: to}
+	}
+}
+
+// Context sets the context used by transitions made on state timeouts
+func Context(ctx context.Context) Option {
+	return func(o *Options) {
+		o.Context = ctx
+	}
+}
+
+// Logger sets the logger
+func Logger(l logger.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
 // NewOptions returns new Options struct filled by passed Option
 func NewOptions(opts ...Option) Options {
-	options := Options{}
+	options := Options{
+		Context: context.Background(),
+		Logger:  logger.DefaultLogger,
+	}
 	for _, o := range opts {
 		o(&options)
 	}
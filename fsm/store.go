@@ -0,0 +1,171 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.unistack.org/micro/v3/store"
+)
+
+var (
+	// ErrInstanceNotExists returns when persisted instance not found in store
+	ErrInstanceNotExists = errors.New("instance not exists")
+	// ErrConcurrentModification returns when persisted instance changed by other state machine since it was read
+	ErrConcurrentModification = errors.New("instance modified concurrently")
+)
+
+// instance holds persisted state machine instance
+type instance struct {
+	Updated time.Time            `json:"updated"`
+	Body    interface{}          `json:"body,omitempty"`
+	Timers  map[string]time.Time `json:"timers,omitempty"`
+	State   string               `json:"state"`
+	Version int64                `json:"version"`
+}
+
+// persist writes current state and body to the store of the run options if instance id and store set,
+// write fails if instance version in store differs from version known by state machine.
+// Store has no compare and swap, so read, version check and write serialized by Sync lock if it set,
+// otherwise processes that write the same instance at the same time can overwrite each other
+func (f *fsm) persist(ctx context.Context, options Options) error {
+	if options.Store == nil || options.ID == "" {
+		return nil
+	}
+
+	// snapshot taken under lock, store io done without it
+	f.mu.Lock()
+	version := f.version
+	rec := &instance{State: f.current, Body: f.body, Version: version + 1, Updated: time.Now().UTC()}
+	for name, t := range f.timers {
+		if rec.Timers == nil {
+			rec.Timers = make(map[string]time.Time, len(f.timers))
+		}
+		rec.Timers[name] = t.deadline
+	}
+	f.mu.Unlock()
+
+	if options.Sync != nil {
+		lockID := "fsm/" + options.ID
+		if err := options.Sync.Lock(lockID); err != nil {
+			return err
+		}
+		defer func() {
+			_ = options.Sync.Unlock(lockID)
+		}()
+	}
+
+	old := &instance{}
+	err := options.Store.Read(ctx, options.ID, old)
+	switch {
+	case err == store.ErrNotFound:
+		old.Version = 0
+	case err != nil:
+		return err
+	}
+	if old.Version != version {
+		return fmt.Errorf(`instance "%s" version %d, expected %d: %w`, options.ID, old.Version, version, ErrConcurrentModification)
+	}
+
+	if err = options.Store.Write(ctx, options.ID, rec); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.version = rec.Version
+	f.mu.Unlock()
+
+	return nil
+}
+
+// syncVersion sets known version to the version of persisted instance, so new run can replace it
+func (f *fsm) syncVersion(ctx context.Context, options Options) error {
+	if options.Store == nil || options.ID == "" {
+		return nil
+	}
+
+	rec := &instance{}
+	if err := options.Store.Read(ctx, options.ID, rec); err == store.ErrNotFound {
+		rec.Version = 0
+	} else if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.version = rec.Version
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Restore loads current state and body of the instance from the store and restarts timers of state timeouts,
+// expired timeouts fire immediately. Declarative state machine continues by Fire, otherwise by Resume
+func (f *fsm) Restore(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.opts.Store == nil || f.opts.ID == "" {
+		return ErrInstanceNotExists
+	}
+
+	rec := &instance{}
+	if f.opts.NewBody != nil {
+		rec.Body = f.opts.NewBody()
+	}
+	if err := f.opts.Store.Read(ctx, f.opts.ID, rec); err == store.ErrNotFound {
+		return ErrInstanceNotExists
+	} else if err != nil {
+		return err
+	}
+
+	f.current = rec.State
+	f.body = rec.Body
+	f.version = rec.Version
+
+	f.stopTimers()
+	for name, deadline := range rec.Timers {
+		if _, ok := f.opts.Timeouts[name]; ok && stateActive(f.current, name) {
+			f.startTimer(name, deadline, f.opts)
+		}
+	}
+
+	return nil
+}
+
+// Resume runs state funcs starting from the current state with the current body,
+// state func of the current state executed again, so it must be idempotent
+func (f *fsm) Resume(ctx context.Context) (interface{}, error) {
+	f.mu.Lock()
+	options := f.opts
+	s := &state{name: f.current, body: f.body}
+	states := make(map[string]StateFunc, len(f.statesMap))
+	for k, v := range f.statesMap {
+		states[k] = v
+	}
+	f.mu.Unlock()
+
+	if s.name == "" {
+		return nil, ErrNotStarted
+	}
+	if s.name == StateEnd {
+		return s.body, nil
+	}
+
+	if len(options.Transitions) > 0 {
+		f.fire.Lock()
+		defer f.fire.Unlock()
+
+		var queue []pending
+		for _, leaf := range activeStates(s.name) {
+			queue = append(queue, pending{to: leaf, resume: true})
+		}
+		return f.dispatch(ctx, s.body, queue, states, options)
+	}
+
+	if err := checkTimeouts(options); err != nil {
+		return nil, err
+	}
+
+	return f.run(ctx, s, s.name, states, options)
+}
@@ -0,0 +1,183 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/store"
+	"go.unistack.org/micro/v3/sync"
+)
+
+type testOrder struct {
+	Amount int `json:"amount"`
+}
+
+func newTestOrderFSM(s store.Store) *fsm {
+	f := NewFSM(
+		InitialState("created"),
+		Store(s),
+		ID("order-1"),
+		NewBody(func() interface{} { return &testOrder{} }),
+		Transitions(
+			Transition{From: "created", Event: "pay", To: "paid"},
+			Transition{From: "paid", Event: "ship", To: "shipped"},
+		),
+	)
+	f.State("paid", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		order := s.Body().(*testOrder)
+		return &state{name: "", body: &testOrder{Amount: order.Amount * 2}}, nil
+	})
+	return f
+}
+
+func TestFSMRestore(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewStore()
+
+	if err := newTestOrderFSM(s).Restore(ctx); err != ErrInstanceNotExists {
+		t.Fatalf("expected ErrInstanceNotExists, got %v", err)
+	}
+
+	if _, err := newTestOrderFSM(s).Start(ctx, &testOrder{Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// process restarted
+	f := newTestOrderFSM(s)
+	if err := f.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if f.Current() != "created" {
+		t.Fatalf("invalid state %s", f.Current())
+	}
+	rsp, err := f.Fire(ctx, "pay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := rsp.(*testOrder); order.Amount != 20 {
+		t.Fatalf("invalid body %#v", order)
+	}
+
+	f = newTestOrderFSM(s)
+	if err = f.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if f.Current() != "paid" || f.body.(*testOrder).Amount != 20 {
+		t.Fatalf("invalid restored instance %s %#v", f.Current(), f.body)
+	}
+}
+
+func TestFSMConcurrentModification(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewStore()
+
+	if _, err := newTestOrderFSM(s).Start(ctx, &testOrder{Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	f1 := newTestOrderFSM(s)
+	f2 := newTestOrderFSM(s)
+	if err := f1.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := f2.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f1.Fire(ctx, "pay"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f2.Fire(ctx, "pay"); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+}
+
+func TestFSMPersistSync(t *testing.T) {
+	ctx := context.TODO()
+	sy := sync.NewSync()
+	f := newTestOrderFSM(store.NewStore())
+	f.opts.Sync = sy
+
+	if err := sy.Lock("fsm/order-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := f.Start(ctx, &testOrder{Amount: 10})
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("persist must wait for instance lock, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := sy.Unlock("fsm/order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSMPersistStartOptions(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewStore()
+	f := NewFSM(InitialState("created"))
+	f.State("created", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+		return &state{name: StateEnd, body: s.Body()}, nil
+	})
+
+	if _, err := f.Start(ctx, &testOrder{Amount: 10}, Store(s), ID("order-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &instance{Body: &testOrder{}}
+	if err := s.Read(ctx, "order-2", rec); err != nil {
+		t.Fatal(err)
+	}
+	if order := rec.Body.(*testOrder); rec.State != "created" || order.Amount != 10 {
+		t.Fatalf("invalid instance %#v", rec)
+	}
+}
+
+func TestFSMResume(t *testing.T) {
+	ctx := context.TODO()
+	s := store.NewStore()
+
+	var calls int
+	newFSM := func(fail bool) *fsm {
+		f := NewFSM(InitialState("1"), Store(s), ID("resume"))
+		f.State("1", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+			calls++
+			return &state{name: "2", body: s.Body()}, nil
+		})
+		f.State("2", func(_ context.Context, s State, _ ...StateOption) (State, error) {
+			if fail {
+				return nil, errors.New("crash")
+			}
+			return &state{name: StateEnd, body: "done"}, nil
+		})
+		return f
+	}
+
+	if _, err := newFSM(true).Start(ctx, "start"); err == nil {
+		t.Fatal("start must fail")
+	}
+
+	f := newFSM(false)
+	if err := f.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := f.Resume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp != "done" || calls != 1 {
+		t.Fatalf("invalid resume result %v calls %d", rsp, calls)
+	}
+}
@@ -0,0 +1,118 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Timeout describes transition made when state active longer than After,
+// Event fired if it set, otherwise state machine moved to To
+type Timeout struct {
+	// Event fired on timeout
+	Event string
+	// To state entered on timeout
+	To string
+	// After specifies state timeout
+	After time.Duration
+}
+
+// stateTimer holds running timer of the state timeout
+type stateTimer struct {
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// startTimer starts timer that expires state at deadline, must be called with f.mu held
+func (f *fsm) startTimer(name string, deadline time.Time, options Options) {
+	f.stopTimer(name)
+	if f.timers == nil {
+		f.timers = make(map[string]*stateTimer)
+	}
+	t := &stateTimer{deadline: deadline}
+	t.timer = time.AfterFunc(time.Until(deadline), func() { f.expire(name, t, options) })
+	f.timers[name] = t
+}
+
+// stopTimer stops timer of the state, must be called with f.mu held
+func (f *fsm) stopTimer(name string) {
+	if t, ok := f.timers[name]; ok {
+		t.timer.Stop()
+		delete(f.timers, name)
+	}
+}
+
+// stopTimers stops all timers, must be called with f.mu held
+func (f *fsm) stopTimers() {
+	for name := range f.timers {
+		f.stopTimer(name)
+	}
+}
+
+// deadline returns deadline of the state timeout, must be called with f.mu held
+func (f *fsm) deadline(name string) (time.Time, bool) {
+	if t, ok := f.timers[name]; ok {
+		return t.deadline, true
+	}
+	return time.Time{}, false
+}
+
+// expire makes timeout transition of the state if timer was not stopped or restarted meanwhile
+func (f *fsm) expire(name string, t *stateTimer, options Options) {
+	f.fire.Lock()
+	defer f.fire.Unlock()
+
+	f.mu.Lock()
+	if f.timers[name] != t {
+		f.mu.Unlock()
+		return
+	}
+	delete(f.timers, name)
+	body := f.body
+	states := make(map[string]StateFunc, len(f.statesMap))
+	for k, v := range f.statesMap {
+		states[k] = v
+	}
+	f.mu.Unlock()
+
+	ctx := options.Context
+	if ctx.Err() != nil {
+		return
+	}
+
+	timeout := options.Timeouts[name]
+	p := pending{from: name, to: timeout.To}
+	if timeout.Event != "" {
+		tr, from, err := findTransition(ctx, body, name, func(t Transition) bool { return t.Event == timeout.Event }, options.Transitions)
+		if err != nil {
+			options.Logger.Errorf(ctx, `fsm state "%s" timeout event "%s" error: %v`, name, timeout.Event, err)
+			return
+		}
+		p = pending{from: from, to: tr.To, event: timeout.Event}
+	}
+
+	if _, err := f.dispatch(ctx, body, []pending{p}, states, options); err != nil {
+		options.Logger.Errorf(ctx, `fsm state "%s" timeout error: %v`, name, err)
+	}
+}
+
+// checkTimeouts checks that timeouts can be used without transitions table
+func checkTimeouts(options Options) error {
+	for name, t := range options.Timeouts {
+		if t.Event != "" {
+			return fmt.Errorf(`state "%s" timeout event "%s" %w`, name, t.Event, ErrTimeoutEvent)
+		}
+	}
+	return nil
+}
+
+// stateContext returns context expired on state timeout, used to run state func without transitions table
+func stateContext(ctx context.Context, name string, options Options, sopts []StateOption) (context.Context, context.CancelFunc, []StateOption) {
+	t, ok := options.Timeouts[name]
+	if !ok {
+		return ctx, func() {}, sopts
+	}
+	deadline := time.Now().Add(t.After)
+	sctx, cancel := context.WithDeadline(ctx, deadline)
+	return sctx, cancel, append(sopts[:len(sopts):len(sopts)], StateDeadline(deadline))
+}
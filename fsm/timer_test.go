@@ -0,0 +1,172 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/store"
+)
+
+func waitState(t *testing.T, f FSM, name string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for f.Current() != name {
+		if time.Now().After(deadline) {
+			t.Fatalf("state %s not reached, current %s", name, f.Current())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFSMTimeoutEvent(t *testing.T) {
+	ctx := context.TODO()
+
+	var deadline time.Time
+	f := NewFSM(
+		InitialState("awaiting_payment"),
+		TimeoutEvent("awaiting_payment", 20*time.Millisecond, "expire"),
+		Transitions(
+			Transition{From: "awaiting_payment", Event: "expire", To: "cancelled"},
+			Transition{From: "awaiting_payment", Event: "pay", To: "paid"},
+		),
+	)
+	f.State("awaiting_payment", func(_ context.Context, s State, opts ...StateOption) (State, error) {
+		options := StateOptions{}
+		for _, o := range opts {
+			o(&options)
+		}
+		deadline = options.Deadline
+		return nil, nil
+	})
+
+	if _, err := f.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if deadline.IsZero() {
+		t.Fatal("state func must receive timeout deadline")
+	}
+
+	waitState(t, f, "cancelled")
+
+	history := f.History()
+	if last := history[len(history)-1]; last.Event != "expire" {
+		t.Fatalf("invalid last transition %#v", last)
+	}
+}
+
+func TestFSMTimeoutCancelled(t *testing.T) {
+	ctx := context.TODO()
+
+	f := NewFSM(
+		InitialState("pending"),
+		TimeoutState("pending", 20*time.Millisecond, "expired"),
+		Transitions(
+			Transition{From: "pending", Event: "pay", To: "paid"},
+		),
+	)
+
+	if _, err := f.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fire(ctx, "pay"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if f.Current() != "paid" {
+		t.Fatalf("timeout of exited state must be cancelled, current %s", f.Current())
+	}
+}
+
+func TestFSMTimeoutRestore(t *testing.T) {
+	ctx := context.TODO()
+	st := store.NewStore()
+
+	opts := []Option{
+		Store(st),
+		ID("order"),
+		InitialState("pending"),
+		TimeoutState("pending", 50*time.Millisecond, "expired"),
+		Transitions(
+			Transition{From: "pending", Event: "pay", To: "paid"},
+		),
+	}
+
+	f1 := NewFSM(opts...)
+	if _, err := f1.Start(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	// process stopped before timeout
+	f1.Reset()
+
+	f2 := NewFSM(opts...)
+	if err := f2.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if f2.Current() != "pending" {
+		t.Fatalf("invalid restored state %s", f2.Current())
+	}
+
+	waitState(t, f2, "expired")
+
+	// transition persisted after state changed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := &instance{}
+		if err := st.Read(ctx, "order", rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.State == "expired" && len(rec.Timers) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("invalid persisted instance %#v", rec)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFSMTimeoutImperative(t *testing.T) {
+	ctx := context.TODO()
+
+	f := NewFSM(InitialState("charge"), TimeoutState("charge", 20*time.Millisecond, "cancelled"))
+	var deadline time.Time
+	f.State("charge", func(sctx context.Context, s State, opts ...StateOption) (State, error) {
+		options := StateOptions{}
+		for _, o := range opts {
+			o(&options)
+		}
+		deadline = options.Deadline
+		<-sctx.Done()
+		return &state{name: "charged", body: "charged"}, sctx.Err()
+	})
+	f.State("charged", func(_ context.Context, s State, opts ...StateOption) (State, error) {
+		return &state{name: StateEnd, body: s.Body()}, nil
+	})
+	f.State("cancelled", func(_ context.Context, s State, opts ...StateOption) (State, error) {
+		return &state{name: StateEnd, body: "cancelled"}, nil
+	})
+
+	rsp, err := f.Start(ctx, "init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deadline.IsZero() {
+		t.Fatal("state func must receive timeout deadline")
+	}
+	if rsp != "cancelled" || f.Current() != "cancelled" {
+		t.Fatalf("timed out state must move to timeout target, got %v in %s", rsp, f.Current())
+	}
+}
+
+func TestFSMTimeoutEventImperative(t *testing.T) {
+	f := NewFSM(InitialState("charge"), TimeoutEvent("charge", time.Second, "expire"))
+	f.State("charge", func(_ context.Context, s State, opts ...StateOption) (State, error) {
+		return &state{name: StateEnd}, nil
+	})
+
+	if _, err := f.Start(context.TODO(), nil); !errors.Is(err, ErrTimeoutEvent) {
+		t.Fatalf("timeout event without transitions must be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package fsm
+
+import (
+	"context"
+	"time"
+
+	"go.unistack.org/micro/v3/meter"
+	"go.unistack.org/micro/v3/tracer"
+)
+
+var (
+	// StateDurationSeconds specifies meter metric name for state func duration
+	StateDurationSeconds = "fsm_state_duration_seconds"
+	// StateRequestTotal specifies meter metric name for number of state func calls
+	StateRequestTotal = "fsm_state_request_total"
+
+	labelState   = "state"
+	labelStatus  = "status"
+	labelSuccess = "success"
+	labelFailure = "failure"
+)
+
+// TracerWrapper returns StateWrapper that runs each state func in its own span
+func TracerWrapper(t tracer.Tracer) StateWrapper {
+	return func(fn StateFunc) StateFunc {
+		return func(ctx context.Context, s State, opts ...StateOption) (State, error) {
+			ctx, sp := t.Start(ctx, "FSM.State "+s.Name(),
+				tracer.WithSpanKind(tracer.SpanKindInternal),
+				tracer.WithSpanLabels(labelState, s.Name()),
+			)
+			defer sp.Finish()
+
+			ns, err := fn(ctx, s, opts...)
+			if err != nil {
+				sp.SetStatus(tracer.SpanStatusError, err.Error())
+			}
+			return ns, err
+		}
+	}
+}
+
+// MeterWrapper returns StateWrapper that counts state func calls and measures their duration
+func MeterWrapper(m meter.Meter) StateWrapper {
+	return func(fn StateFunc) StateFunc {
+		return func(ctx context.Context, s State, opts ...StateOption) (State, error) {
+			ts := time.Now()
+			ns, err := fn(ctx, s, opts...)
+
+			status := labelSuccess
+			if err != nil {
+				status = labelFailure
+			}
+			m.Counter(StateRequestTotal, labelState, s.Name(), labelStatus, status).Inc()
+			m.Histogram(StateDurationSeconds, labelState, s.Name(), labelStatus, status).UpdateDuration(ts)
+
+			return ns, err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// gradientProbe specifies number of samples after which the lowest latency measured again
+const gradientProbe = 1000
+
+// concurrency holds common state of concurrency limiters
+type concurrency struct {
+	update   func(latency time.Duration, dropped bool)
+	opts     Options
+	limit    float64
+	inflight int
+	sync.Mutex
+}
+
+func newConcurrency(opts ...Option) *concurrency {
+	options := NewOptions(opts...)
+	if options.MinLimit < 1 {
+		options.MinLimit = 1
+	}
+	if options.MaxLimit < options.MinLimit {
+		options.MaxLimit = options.MinLimit
+	}
+	c := &concurrency{opts: options, limit: float64(options.InitialLimit)}
+	c.clamp()
+	return c
+}
+
+func (c *concurrency) Acquire() bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.inflight >= int(c.limit) {
+		return false
+	}
+	c.inflight++
+
+	return true
+}
+
+func (c *concurrency) Release(latency time.Duration, dropped bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.update(latency, dropped)
+	c.clamp()
+	if c.inflight > 0 {
+		c.inflight--
+	}
+}
+
+func (c *concurrency) Limit() int {
+	c.Lock()
+	defer c.Unlock()
+	return int(c.limit)
+}
+
+func (c *concurrency) Inflight() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.inflight
+}
+
+func (c *concurrency) clamp() {
+	c.limit = math.Max(float64(c.opts.MinLimit), math.Min(float64(c.opts.MaxLimit), c.limit))
+}
+
+// NewAIMD returns limiter that increases limit by one after successful request while limit utilized
+// and multiplies limit by Backoff after request dropped or slower than Timeout
+func NewAIMD(opts ...Option) Limiter {
+	c := newConcurrency(opts...)
+	c.update = func(latency time.Duration, dropped bool) {
+		switch {
+		case dropped || latency > c.opts.Timeout:
+			c.limit *= c.opts.Backoff
+		case c.inflight*2 >= int(c.limit):
+			c.limit++
+		}
+	}
+	return c
+}
+
+// NewGradient returns limiter that adjusts limit by ratio of the lowest observed latency to request latency,
+// so limit decreased when requests queued and latency grows
+func NewGradient(opts ...Option) Limiter {
+	c := newConcurrency(opts...)
+	var minLatency time.Duration
+	var samples int
+	c.update = func(latency time.Duration, dropped bool) {
+		if dropped {
+			c.limit *= c.opts.Backoff
+			return
+		}
+
+		samples++
+		if minLatency == 0 || latency < minLatency || samples%gradientProbe == 0 {
+			minLatency = latency
+		}
+		if latency <= 0 {
+			return
+		}
+
+		gradient := math.Max(0.5, math.Min(1, c.opts.Tolerance*float64(minLatency)/float64(latency)))
+		// limit not utilized, so latency says nothing about higher limit
+		if gradient == 1 && c.inflight*2 < int(c.limit) {
+			return
+		}
+		limit := c.limit*gradient + math.Sqrt(c.limit)
+		c.limit = c.limit*(1-c.opts.Smoothing) + limit*c.opts.Smoothing
+	}
+	return c
+}
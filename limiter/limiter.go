@@ -0,0 +1,69 @@
+// Package limiter provides rate and concurrency limiters used to protect services from overload
+package limiter // import "go.unistack.org/micro/v3/limiter"
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter limits number of requests per second
+type RateLimiter interface {
+	// Allow takes token for request, false returned if no tokens available
+	Allow() bool
+	// Rate returns number of requests allowed per second
+	Rate() float64
+}
+
+// Limiter limits number of concurrent requests, limit adjusted by observed latency
+type Limiter interface {
+	// Acquire takes slot for request, false returned if limit reached
+	Acquire() bool
+	// Release frees slot and adjusts limit, dropped reports that request failed because of overload
+	Release(latency time.Duration, dropped bool)
+	// Limit returns current limit
+	Limit() int
+	// Inflight returns number of acquired slots
+	Inflight() int
+}
+
+type bucket struct {
+	last   time.Time
+	rate   float64
+	burst  float64
+	tokens float64
+	sync.Mutex
+}
+
+// NewBucket returns token bucket rate limiter that allows rate requests per second with bursts up to burst requests
+func NewBucket(rate float64, burst int) RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (b *bucket) Allow() bool {
+	now := time.Now()
+
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+func (b *bucket) Rate() float64 {
+	return b.rate
+}
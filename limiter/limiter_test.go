@@ -0,0 +1,62 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	b := NewBucket(1000, 2)
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("burst must be allowed")
+	}
+	if b.Allow() {
+		t.Fatal("request over burst must be rejected")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("tokens must be refilled")
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	l := NewAIMD(InitialLimit(2), Timeout(time.Second))
+
+	if !l.Acquire() || !l.Acquire() {
+		t.Fatal("requests within limit must be acquired")
+	}
+	if l.Acquire() {
+		t.Fatal("request over limit must be rejected")
+	}
+
+	l.Release(time.Millisecond, false)
+	if l.Limit() != 3 {
+		t.Fatalf("limit must be increased, got %d", l.Limit())
+	}
+
+	for i := 0; i < 10; i++ {
+		if l.Acquire() {
+			l.Release(time.Millisecond, true)
+		}
+	}
+	if l.Limit() != 1 || l.Inflight() != 1 {
+		t.Fatalf("limit must be decreased to min, got %d inflight %d", l.Limit(), l.Inflight())
+	}
+}
+
+func TestGradient(t *testing.T) {
+	l := NewGradient(InitialLimit(100), Smoothing(1), Tolerance(1))
+
+	// fill the limit, so latency says about limit
+	for i := 0; i < 100; i++ {
+		l.Acquire()
+	}
+	l.Release(10*time.Millisecond, false)
+	l.Acquire()
+	// latency grows because of queueing
+	l.Release(20*time.Millisecond, false)
+
+	if limit := l.Limit(); limit >= 100 {
+		t.Fatalf("limit must be decreased by latency growth, got %d", limit)
+	}
+}
@@ -0,0 +1,90 @@
+package limiter
+
+import "time"
+
+// Options holds concurrency limiter options
+type Options struct {
+	// InitialLimit specifies limit before any latency observed
+	InitialLimit int
+	// MinLimit specifies lowest limit
+	MinLimit int
+	// MaxLimit specifies highest limit
+	MaxLimit int
+	// Backoff specifies ratio limit multiplied by on overload
+	Backoff float64
+	// Timeout specifies latency treated as overload by AIMD limiter
+	Timeout time.Duration
+	// Smoothing specifies weight of new limit calculated by gradient limiter
+	Smoothing float64
+	// Tolerance specifies ratio of latency to the lowest latency not treated as queueing by gradient limiter
+	Tolerance float64
+}
+
+// Option func signature
+type Option func(*Options)
+
+// NewOptions returns new Options filled by passed Option
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     1000,
+		Backoff:      0.9,
+		Timeout:      5 * time.Second,
+		Smoothing:    0.2,
+		Tolerance:    1.5,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// InitialLimit sets limit before any latency observed
+func InitialLimit(n int) Option {
+	return func(o *Options) {
+		o.InitialLimit = n
+	}
+}
+
+// MinLimit sets lowest limit
+func MinLimit(n int) Option {
+	return func(o *Options) {
+		o.MinLimit = n
+	}
+}
+
+// MaxLimit sets highest limit
+func MaxLimit(n int) Option {
+	return func(o *Options) {
+		o.MaxLimit = n
+	}
+}
+
+// Backoff sets ratio limit multiplied by on overload, like 0.9
+func Backoff(r float64) Option {
+	return func(o *Options) {
+		o.Backoff = r
+	}
+}
+
+// Timeout sets latency treated as overload by AIMD limiter
+func Timeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// Smoothing sets weight of new limit calculated by gradient limiter, between 0 and 1
+func Smoothing(r float64) Option {
+	return func(o *Options) {
+		o.Smoothing = r
+	}
+}
+
+// Tolerance sets ratio of latency to the lowest latency not treated as queueing by gradient limiter
+func Tolerance(r float64) Option {
+	return func(o *Options) {
+		o.Tolerance = r
+	}
+}
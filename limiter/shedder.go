@@ -0,0 +1,119 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Request priorities, low priority requests shed first
+const (
+	PriorityLow = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+// priorityShare holds part of max in-flight requests available for priority
+var priorityShare = [...]float64{0.5, 0.8, 0.9, 1}
+
+// Shedder limits number of requests in flight, requests with lower priority shed at lower load
+type Shedder interface {
+	// Acquire takes slot for request, waits for free slot up to queue time, false returned if request must be shed
+	Acquire(ctx context.Context, priority int) bool
+	// Release frees slot
+	Release()
+	// Inflight returns number of acquired slots
+	Inflight() int
+}
+
+type shedder struct {
+	waiters  []chan struct{}
+	max      int
+	wait     time.Duration
+	inflight int
+	sync.Mutex
+}
+
+// NewShedder returns shedder that allows up to max requests in flight, request with PriorityCritical can use
+// all slots, lower priorities can use part of them. Request waits for free slot up to wait.
+func NewShedder(max int, wait time.Duration) Shedder {
+	return &shedder{max: max, wait: wait}
+}
+
+func (s *shedder) Acquire(ctx context.Context, priority int) bool {
+	if priority < PriorityLow {
+		priority = PriorityLow
+	} else if priority > PriorityCritical {
+		priority = PriorityCritical
+	}
+	limit := int(float64(s.max) * priorityShare[priority])
+	if limit < 1 {
+		limit = 1
+	}
+
+	var timeout <-chan time.Time
+	if s.wait > 0 {
+		timer := time.NewTimer(s.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		s.Lock()
+		if s.inflight < limit {
+			s.inflight++
+			s.Unlock()
+			return true
+		}
+		if timeout == nil {
+			s.Unlock()
+			return false
+		}
+		ch := make(chan struct{})
+		s.waiters = append(s.waiters, ch)
+		s.Unlock()
+
+		select {
+		case <-ch:
+		case <-timeout:
+			s.remove(ch)
+			return false
+		case <-ctx.Done():
+			s.remove(ch)
+			return false
+		}
+	}
+}
+
+func (s *shedder) Release() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.inflight > 0 {
+		s.inflight--
+	}
+	// waiters check their limits again
+	for _, ch := range s.waiters {
+		close(ch)
+	}
+	s.waiters = nil
+}
+
+func (s *shedder) Inflight() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.inflight
+}
+
+func (s *shedder) remove(ch chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	for idx, w := range s.waiters {
+		if w == ch {
+			s.waiters = append(s.waiters[:idx], s.waiters[idx+1:]...)
+			return
+		}
+	}
+}
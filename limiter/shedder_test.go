@@ -0,0 +1,52 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShedder(t *testing.T) {
+	s := NewShedder(10, 0)
+
+	for i := 0; i < 5; i++ {
+		if !s.Acquire(context.TODO(), PriorityLow) {
+			t.Fatal("low priority request within share must be acquired")
+		}
+	}
+	if s.Acquire(context.TODO(), PriorityLow) {
+		t.Fatal("low priority request over share must be shed")
+	}
+	for i := 0; i < 5; i++ {
+		if !s.Acquire(context.TODO(), PriorityCritical) {
+			t.Fatal("critical request within limit must be acquired")
+		}
+	}
+	if s.Acquire(context.TODO(), PriorityCritical) {
+		t.Fatal("request over limit must be shed")
+	}
+	if s.Inflight() != 10 {
+		t.Fatalf("inflight must be 10, got %d", s.Inflight())
+	}
+}
+
+func TestShedderQueue(t *testing.T) {
+	s := NewShedder(1, time.Second)
+	if !s.Acquire(context.TODO(), PriorityCritical) {
+		t.Fatal("request must be acquired")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Release()
+	}()
+	if !s.Acquire(context.TODO(), PriorityCritical) {
+		t.Fatal("queued request must be acquired after release")
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if s.Acquire(ctx, PriorityCritical) {
+		t.Fatal("queued request must be shed after context done")
+	}
+}
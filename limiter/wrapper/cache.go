@@ -0,0 +1,56 @@
+package wrapper
+
+import (
+	"container/list"
+	"sync"
+
+	"go.unistack.org/micro/v3/limiter"
+)
+
+// bucketCache holds rate limiters by key, least recently used limiter evicted when cache is full
+type bucketCache struct {
+	items map[string]*list.Element
+	order *list.List
+	size  int
+	sync.Mutex
+}
+
+type bucketEntry struct {
+	limiter limiter.RateLimiter
+	key     string
+}
+
+func newBucketCache(size int) *bucketCache {
+	if size < 1 {
+		size = 1
+	}
+	return &bucketCache{items: make(map[string]*list.Element), order: list.New(), size: size}
+}
+
+// get returns limiter by key, limiter created by fn if not cached
+func (c *bucketCache) get(key string, fn func() limiter.RateLimiter) limiter.RateLimiter {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*bucketEntry).limiter
+	}
+
+	if c.order.Len() >= c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*bucketEntry).key)
+	}
+
+	l := fn()
+	c.items[key] = c.order.PushFront(&bucketEntry{key: key, limiter: l})
+	return l
+}
+
+// len returns number of cached limiters
+func (c *bucketCache) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,333 @@
+package wrapper
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/limiter"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/meter"
+	"go.unistack.org/micro/v3/server"
+)
+
+var (
+	// MetadataRateLimit is the endpoint metadata key that holds requests per second allowed for endpoint
+	MetadataRateLimit = "rate_limit"
+	// MetadataRateBurst is the endpoint metadata key that holds burst of endpoint rate limit,
+	// by default burst equals to rate
+	MetadataRateBurst = "rate_burst"
+	// MetadataCallerRateLimit is the endpoint metadata key that holds requests per second allowed for each caller
+	MetadataCallerRateLimit = "caller_rate_limit"
+	// MetadataCallerRateBurst is the endpoint metadata key that holds burst of caller rate limit,
+	// by default burst equals to caller rate
+	MetadataCallerRateBurst = "caller_rate_burst"
+	// MetadataMaxInflight is the endpoint metadata key that holds max number of endpoint requests handled at the same time
+	MetadataMaxInflight = "max_inflight"
+
+	// ServerRateLimit specifies meter metric name for requests per second allowed by endpoint rate limiter
+	ServerRateLimit = "server_rate_limit"
+	// ServerSheddingInflight specifies meter metric name for requests counted by load shedding
+	ServerSheddingInflight = "server_shedding_inflight"
+	// ServerRequestRejectedTotal specifies meter metric name for server requests rejected by limiters
+	ServerRequestRejectedTotal = "server_request_rejected_total"
+
+	// DefaultMaxCallers specifies default max number of callers rate limiters
+	DefaultMaxCallers = 10000
+
+	labelReason = "reason"
+	reasonRate  = "rate"
+	reasonShed  = "shed"
+)
+
+// ServerOptions struct holds server handler wrapper options
+type ServerOptions struct {
+	Meter meter.Meter
+	// Endpoints holds endpoint metadata with limits declared by server.EndpointMetadata
+	Endpoints map[string]metadata.Metadata
+	// CallerHeader specifies metadata key that holds caller identity
+	CallerHeader string
+	// PriorityHeader specifies metadata key that holds request priority
+	PriorityHeader string
+	SkipEndpoints  []string
+	// MaxInflight specifies max number of requests handled by server at the same time
+	MaxInflight int
+	// MaxCallers specifies max number of callers rate limiters, least recently used evicted
+	MaxCallers int
+	// MaxQueueTime specifies how long request waits for free slot before shed
+	MaxQueueTime time.Duration
+	// RetryAfter specifies retry hint returned with shed requests
+	RetryAfter time.Duration
+	// TrustPriority allows callers to raise request priority above limiter.PriorityNormal
+	TrustPriority bool
+}
+
+// ServerOption func signature
+type ServerOption func(*ServerOptions)
+
+// NewServerOptions creates new ServerOptions struct
+func NewServerOptions(opts ...ServerOption) ServerOptions {
+	options := ServerOptions{
+		Meter:          meter.DefaultMeter,
+		SkipEndpoints:  DefaultSkipEndpoints,
+		CallerHeader:   metadata.HeaderCaller,
+		PriorityHeader: metadata.HeaderPriority,
+		RetryAfter:     time.Second,
+		MaxCallers:     DefaultMaxCallers,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// ServerMeter passes meter
+func ServerMeter(m meter.Meter) ServerOption {
+	return func(o *ServerOptions) {
+		o.Meter = m
+	}
+}
+
+// ServerSkipEndpoints add endpoint to skip
+func ServerSkipEndpoints(eps ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.SkipEndpoints = append(o.SkipEndpoints, eps...)
+	}
+}
+
+// Handlers passes endpoint metadata of handlers, so server wrapper uses limits declared by server.EndpointMetadata
+func Handlers(hs ...server.Handler) ServerOption {
+	return func(o *ServerOptions) {
+		if o.Endpoints == nil {
+			o.Endpoints = make(map[string]metadata.Metadata)
+		}
+		for _, h := range hs {
+			for _, ep := range h.Endpoints() {
+				o.Endpoints[ep.Name] = ep.Metadata
+			}
+		}
+	}
+}
+
+// CallerHeader sets metadata key that holds caller identity, by default metadata.HeaderCaller
+func CallerHeader(key string) ServerOption {
+	return func(o *ServerOptions) {
+		o.CallerHeader = key
+	}
+}
+
+// PriorityHeader sets metadata key that holds request priority, by default metadata.HeaderPriority
+func PriorityHeader(key string) ServerOption {
+	return func(o *ServerOptions) {
+		o.PriorityHeader = key
+	}
+}
+
+// TrustPriority allows callers to raise request priority above limiter.PriorityNormal by PriorityHeader,
+// enable it only if header set by trusted callers or gateway, otherwise callers can only lower priority
+func TrustPriority(b bool) ServerOption {
+	return func(o *ServerOptions) {
+		o.TrustPriority = b
+	}
+}
+
+// MaxInflight sets max number of requests handled by server at the same time
+func MaxInflight(n int) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxInflight = n
+	}
+}
+
+// MaxCallers sets max number of callers rate limiters, least recently used limiter evicted,
+// so callers can't grow memory by new identities
+func MaxCallers(n int) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxCallers = n
+	}
+}
+
+// MaxQueueTime sets how long request waits for free slot before shed
+func MaxQueueTime(d time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxQueueTime = d
+	}
+}
+
+// RetryAfter sets retry hint returned with shed requests
+func RetryAfter(d time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.RetryAfter = d
+	}
+}
+
+// rateConfig holds rate and burst of rate limiter declared in endpoint metadata
+type rateConfig struct {
+	rate  float64
+	burst int
+}
+
+type serverWrapper struct {
+	rates    map[string]limiter.RateLimiter
+	callers  map[string]rateConfig
+	shedders map[string]limiter.Shedder
+	buckets  *bucketCache
+	shedder  limiter.Shedder
+	opts     ServerOptions
+}
+
+// NewServerHandlerWrapper create new server handler wrapper that enforces rate limits and max in-flight requests
+// declared by server.EndpointMetadata of handlers passed via Handlers option and server wide MaxInflight.
+// Requests with low priority shed first. Rejected requests get errors.TooManyRequests or errors.ServiceUnavailable
+// with metadata.HeaderRetryAfter hint.
+func NewServerHandlerWrapper(opts ...ServerOption) server.HandlerWrapper {
+	w := &serverWrapper{
+		rates:    make(map[string]limiter.RateLimiter),
+		callers:  make(map[string]rateConfig),
+		shedders: make(map[string]limiter.Shedder),
+		opts:     NewServerOptions(opts...),
+	}
+	w.buckets = newBucketCache(w.opts.MaxCallers)
+
+	// limiters created only for declared endpoints, so requests to unknown endpoints can't grow them
+	for endpoint, md := range w.opts.Endpoints {
+		if cfg, ok := parseRate(md, MetadataRateLimit, MetadataRateBurst); ok {
+			l := limiter.NewBucket(cfg.rate, cfg.burst)
+			w.rates[endpoint] = l
+			w.opts.Meter.Gauge(ServerRateLimit, l.Rate, labelEndpoint, endpoint)
+		}
+		if cfg, ok := parseRate(md, MetadataCallerRateLimit, MetadataCallerRateBurst); ok {
+			w.callers[endpoint] = cfg
+		}
+		if v, ok := md.Get(MetadataMaxInflight); ok {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				s := limiter.NewShedder(n, w.opts.MaxQueueTime)
+				w.shedders[endpoint] = s
+				w.opts.Meter.Gauge(ServerSheddingInflight, func() float64 { return float64(s.Inflight()) }, labelEndpoint, endpoint)
+			}
+		}
+	}
+
+	if w.opts.MaxInflight > 0 {
+		s := limiter.NewShedder(w.opts.MaxInflight, w.opts.MaxQueueTime)
+		w.shedder = s
+		w.opts.Meter.Gauge(ServerSheddingInflight, func() float64 { return float64(s.Inflight()) })
+	}
+
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			endpoint := req.Endpoint()
+			for _, ep := range w.opts.SkipEndpoints {
+				if ep == endpoint {
+					return fn(ctx, req, rsp)
+				}
+			}
+
+			if err := w.limitRate(ctx, req); err != nil {
+				w.opts.Meter.Counter(ServerRequestRejectedTotal, labelEndpoint, endpoint, labelReason, reasonRate).Inc()
+				return err
+			}
+
+			priority := w.priority(ctx, req)
+			for _, s := range []limiter.Shedder{w.shedder, w.shedders[endpoint]} {
+				if s == nil {
+					continue
+				}
+				if !s.Acquire(ctx, priority) {
+					w.opts.Meter.Counter(ServerRequestRejectedTotal, labelEndpoint, endpoint, labelReason, reasonShed).Inc()
+					return withRetryAfter(errors.ServiceUnavailable("go.micro.server", "server overloaded"), w.opts.RetryAfter)
+				}
+				defer s.Release()
+			}
+
+			return fn(ctx, req, rsp)
+		}
+	}
+}
+
+// limitRate checks caller and endpoint rate limits, caller checked first,
+// so requests of caller over its limit does not consume endpoint tokens
+func (w *serverWrapper) limitRate(ctx context.Context, req server.Request) error {
+	endpoint := req.Endpoint()
+
+	if cfg, ok := w.callers[endpoint]; ok {
+		caller := header(ctx, req, w.opts.CallerHeader)
+		l := w.buckets.get(endpoint+"/"+caller, func() limiter.RateLimiter {
+			return limiter.NewBucket(cfg.rate, cfg.burst)
+		})
+		if !l.Allow() {
+			return withRetryAfter(errors.TooManyRequests("go.micro.server", "rate limit exceeded for %s caller %s", endpoint, caller), rateDelay(l.Rate()))
+		}
+	}
+
+	if l, ok := w.rates[endpoint]; ok && !l.Allow() {
+		return withRetryAfter(errors.TooManyRequests("go.micro.server", "rate limit exceeded for %s", endpoint), rateDelay(l.Rate()))
+	}
+
+	return nil
+}
+
+// parseRate returns rate and burst declared in metadata keys, false if rate not declared
+func parseRate(md metadata.Metadata, key string, burstKey string) (rateConfig, bool) {
+	v, ok := md.Get(key)
+	if !ok {
+		return rateConfig{}, false
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate <= 0 {
+		return rateConfig{}, false
+	}
+	cfg := rateConfig{rate: rate, burst: int(math.Ceil(rate))}
+	if v, ok = md.Get(burstKey); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.burst = n
+		}
+	}
+	return cfg, true
+}
+
+// priority returns request priority from metadata, limiter.PriorityNormal if not set
+func (w *serverWrapper) priority(ctx context.Context, req server.Request) int {
+	n, err := strconv.Atoi(header(ctx, req, w.opts.PriorityHeader))
+	if err != nil {
+		return limiter.PriorityNormal
+	}
+	// untrusted caller can't bypass shedding tiers
+	if n > limiter.PriorityNormal && !w.opts.TrustPriority {
+		return limiter.PriorityNormal
+	}
+	return n
+}
+
+// header returns value of the request header or incoming metadata
+func header(ctx context.Context, req server.Request, key string) string {
+	if v, ok := req.Header().Get(key); ok {
+		return v
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v, ok := md.Get(key); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// rateDelay returns time needed to get new token of rate limiter
+func rateDelay(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// withRetryAfter sets metadata.HeaderRetryAfter of the error in seconds, at least one second
+func withRetryAfter(err error, d time.Duration) error {
+	verr, ok := err.(*errors.Error)
+	if !ok {
+		return err
+	}
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	verr.Metadata = metadata.Metadata{metadata.HeaderRetryAfter: strconv.FormatInt(seconds, 10)}
+	return verr
+}
@@ -0,0 +1,190 @@
+package wrapper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/limiter"
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/server"
+)
+
+type testRequest struct {
+	server.Request
+	header   metadata.Metadata
+	endpoint string
+}
+
+func (r *testRequest) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *testRequest) Header() metadata.Metadata {
+	return r.header
+}
+
+func TestServerRateWrapper(t *testing.T) {
+	w := NewServerHandlerWrapper(func(o *ServerOptions) {
+		o.Endpoints = map[string]metadata.Metadata{
+			"Test.Call":   {MetadataRateLimit: "0.5", MetadataRateBurst: "2"},
+			"Test.Caller": {MetadataCallerRateLimit: "0.5", MetadataCallerRateBurst: "2"},
+		}
+	})
+	h := w(func(ctx context.Context, req server.Request, rsp interface{}) error { return nil })
+
+	req := &testRequest{endpoint: "Test.Call", header: metadata.New(0)}
+	for i := 0; i < 2; i++ {
+		if err := h(context.TODO(), req, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := h(context.TODO(), req, nil)
+	if !errors.CodeIn(err, 429) {
+		t.Fatalf("request over rate must be rejected, got %v", err)
+	}
+	if d, ok := errors.RetryAfter(err); !ok || d != 2*time.Second {
+		t.Fatalf("retry after must be 2s, got %v %v", d, ok)
+	}
+
+	for _, caller := range []string{"a", "b"} {
+		req = &testRequest{endpoint: "Test.Caller", header: metadata.Metadata{metadata.HeaderCaller: caller}}
+		for i := 0; i < 2; i++ {
+			if err = h(context.TODO(), req, nil); err != nil {
+				t.Fatalf("caller %s must have own limiter with own burst, got %v", caller, err)
+			}
+		}
+	}
+	if err = h(context.TODO(), req, nil); !errors.CodeIn(err, 429) {
+		t.Fatalf("caller request over rate must be rejected, got %v", err)
+	}
+}
+
+func TestServerRateWrapperCallerFirst(t *testing.T) {
+	w := NewServerHandlerWrapper(func(o *ServerOptions) {
+		o.Endpoints = map[string]metadata.Metadata{
+			"Test.Call": {MetadataRateLimit: "0.5", MetadataRateBurst: "2", MetadataCallerRateLimit: "0.5", MetadataCallerRateBurst: "1"},
+		}
+	})
+	h := w(func(ctx context.Context, req server.Request, rsp interface{}) error { return nil })
+
+	req := &testRequest{endpoint: "Test.Call", header: metadata.Metadata{metadata.HeaderCaller: "a"}}
+	if err := h(context.TODO(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := h(context.TODO(), req, nil); !errors.CodeIn(err, 429) {
+			t.Fatalf("caller request over rate must be rejected, got %v", err)
+		}
+	}
+
+	req = &testRequest{endpoint: "Test.Call", header: metadata.Metadata{metadata.HeaderCaller: "b"}}
+	if err := h(context.TODO(), req, nil); err != nil {
+		t.Fatalf("rejected caller requests must not consume endpoint rate, got %v", err)
+	}
+}
+
+func TestServerShedWrapper(t *testing.T) {
+	started := make(chan struct{})
+	done := make(chan struct{})
+	w := NewServerHandlerWrapper(MaxInflight(2))
+	h := w(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		started <- struct{}{}
+		<-done
+		return nil
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		req := &testRequest{endpoint: "Test.Call", header: metadata.Metadata{metadata.HeaderPriority: "0"}}
+		errCh <- h(context.TODO(), req, nil)
+	}()
+	<-started
+
+	req := &testRequest{endpoint: "Test.Call", header: metadata.Metadata{metadata.HeaderPriority: "0"}}
+	err := h(context.TODO(), req, nil)
+	if !errors.CodeIn(err, 503) {
+		t.Fatalf("low priority request must be shed, got %v", err)
+	}
+	if d, ok := errors.RetryAfter(err); !ok || d != time.Second {
+		t.Fatalf("retry after must be 1s, got %v %v", d, ok)
+	}
+
+	close(done)
+	if err = <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBucketCache(t *testing.T) {
+	c := newBucketCache(2)
+	var created int
+	fn := func() limiter.RateLimiter {
+		created++
+		return limiter.NewBucket(1, 1)
+	}
+
+	a := c.get("a", fn)
+	c.get("b", fn)
+	if c.get("a", fn) != a {
+		t.Fatal("cached limiter must be returned")
+	}
+	// b is least recently used
+	c.get("c", fn)
+	if c.len() != 2 {
+		t.Fatalf("cache must be bounded, got %d", c.len())
+	}
+	if c.get("a", fn) != a || created != 3 {
+		t.Fatalf("recently used limiter must stay cached, created %d", created)
+	}
+	c.get("b", fn)
+	if created != 4 {
+		t.Fatalf("evicted limiter must be created again, created %d", created)
+	}
+}
+
+func TestServerShedPriority(t *testing.T) {
+	for _, trust := range []bool{false, true} {
+		started := make(chan struct{})
+		done := make(chan struct{})
+		h := NewServerHandlerWrapper(MaxInflight(2), TrustPriority(trust))(func(ctx context.Context, req server.Request, rsp interface{}) error {
+			started <- struct{}{}
+			<-done
+			return nil
+		})
+
+		errCh := make(chan error, 2)
+		go func() {
+			errCh <- h(context.TODO(), &testRequest{endpoint: "Test.Call", header: metadata.New(0)}, nil)
+		}()
+		<-started
+
+		go func() {
+			req := &testRequest{endpoint: "Test.Call", header: metadata.Metadata{metadata.HeaderPriority: "3"}}
+			errCh <- h(context.TODO(), req, nil)
+		}()
+
+		select {
+		case <-started:
+			if !trust {
+				t.Fatal("untrusted caller must not raise priority")
+			}
+		case err := <-errCh:
+			if trust || !errors.CodeIn(err, 503) {
+				t.Fatalf("trust %v: unexpected result %v", trust, err)
+			}
+		}
+
+		close(done)
+		running := 1
+		if trust {
+			running = 2
+		}
+		for i := 0; i < running; i++ {
+			if err := <-errCh; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
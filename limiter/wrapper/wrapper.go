@@ -0,0 +1,329 @@
+package wrapper // import "go.unistack.org/micro/v3/limiter/wrapper"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.unistack.org/micro/v3/client"
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/limiter"
+	"go.unistack.org/micro/v3/meter"
+)
+
+var (
+	// ClientRateLimit specifies meter metric name for requests per second allowed by client rate limiter
+	ClientRateLimit = "client_rate_limit"
+	// ClientConcurrencyLimit specifies meter metric name for current client concurrency limit
+	ClientConcurrencyLimit = "client_concurrency_limit"
+	// ClientConcurrencyInflight specifies meter metric name for client requests counted by concurrency limiter
+	ClientConcurrencyInflight = "client_concurrency_inflight"
+	// ClientRequestRejectedTotal specifies meter metric name for client requests rejected by limiters
+	ClientRequestRejectedTotal = "client_request_rejected_total"
+
+	labelService  = "service"
+	labelEndpoint = "endpoint"
+	labelTopic    = "topic"
+
+	// DefaultSkipEndpoints contains list of endpoints that not limited by wrapper
+	DefaultSkipEndpoints = []string{"Meter.Metrics", "Health.Live", "Health.Ready", "Health.Version"}
+)
+
+// Options struct holds client wrappers options
+type Options struct {
+	Meter         meter.Meter
+	SkipEndpoints []string
+	PerEndpoint   bool
+}
+
+// Option func signature
+type Option func(*Options)
+
+// NewOptions creates new Options struct
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Meter:         meter.DefaultMeter,
+		SkipEndpoints: DefaultSkipEndpoints,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// Meter passes meter
+func Meter(m meter.Meter) Option {
+	return func(o *Options) {
+		o.Meter = m
+	}
+}
+
+// SkipEndoints add endpoint to skip
+func SkipEndoints(eps ...string) Option {
+	return func(o *Options) {
+		o.SkipEndpoints = append(o.SkipEndpoints, eps...)
+	}
+}
+
+// PerEndpoint creates separate limiter for each endpoint instead of one limiter per service
+func PerEndpoint(b bool) Option {
+	return func(o *Options) {
+		o.PerEndpoint = b
+	}
+}
+
+// labels returns meter labels of the limiter used for endpoint, nil if endpoint not limited
+func (o Options) labels(service string, endpoint string) []string {
+	for _, ep := range o.SkipEndpoints {
+		if ep == endpoint {
+			return nil
+		}
+	}
+	if o.PerEndpoint {
+		return []string{labelEndpoint, service + "." + endpoint}
+	}
+	return []string{labelService, service}
+}
+
+// topicLabels returns meter labels of the limiter used for publishing to topic
+func (o Options) topicLabels(topic string) []string {
+	return []string{labelTopic, topic}
+}
+
+// limiterKey returns key of the limiter used for labels, so topic and service limiters not shared
+func limiterKey(labels []string) string {
+	return labels[0] + "/" + labels[1]
+}
+
+// dropped checks that error means overload of the service
+func dropped(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.CodeIn(errors.FromError(err).Code, 408, 429, 503, 504)
+}
+
+type rateWrapper struct {
+	client.Client
+	limiters map[string]limiter.RateLimiter
+	opts     Options
+	rate     float64
+	burst    int
+	sync.Mutex
+}
+
+// NewClientRateWrapper create new client wrapper that rejects calls, streams and publications exceeding
+// rate per second with bursts up to burst requests, request rejected with errors.TooManyRequests.
+// Publications limited per topic.
+func NewClientRateWrapper(rate float64, burst int, opts ...Option) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &rateWrapper{
+			Client:   c,
+			limiters: make(map[string]limiter.RateLimiter),
+			opts:     NewOptions(opts...),
+			rate:     rate,
+			burst:    burst,
+		}
+	}
+}
+
+func (w *rateWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	if err := w.allow(w.opts.labels(req.Service(), req.Endpoint())); err != nil {
+		return err
+	}
+	return w.Client.Call(ctx, req, rsp, opts...)
+}
+
+func (w *rateWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	if err := w.allow(w.opts.labels(req.Service(), req.Endpoint())); err != nil {
+		return nil, err
+	}
+	return w.Client.Stream(ctx, req, opts...)
+}
+
+func (w *rateWrapper) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	if err := w.allow(w.opts.topicLabels(msg.Topic())); err != nil {
+		return err
+	}
+	return w.Client.Publish(ctx, msg, opts...)
+}
+
+func (w *rateWrapper) BatchPublish(ctx context.Context, msgs []client.Message, opts ...client.PublishOption) error {
+	for _, msg := range msgs {
+		if err := w.allow(w.opts.topicLabels(msg.Topic())); err != nil {
+			return err
+		}
+	}
+	return w.Client.BatchPublish(ctx, msgs, opts...)
+}
+
+// allow checks rate limiter used for labels, nil labels not limited
+func (w *rateWrapper) allow(labels []string) error {
+	if labels == nil {
+		return nil
+	}
+	if !w.limiter(labels).Allow() {
+		w.opts.Meter.Counter(ClientRequestRejectedTotal, labels...).Inc()
+		return errors.TooManyRequests("go.micro.client", "rate limit exceeded for %s", labels[1])
+	}
+	return nil
+}
+
+func (w *rateWrapper) limiter(labels []string) limiter.RateLimiter {
+	w.Lock()
+	defer w.Unlock()
+
+	key := limiterKey(labels)
+	l, ok := w.limiters[key]
+	if !ok {
+		l = limiter.NewBucket(w.rate, w.burst)
+		w.limiters[key] = l
+		w.opts.Meter.Gauge(ClientRateLimit, l.Rate, labels...)
+	}
+
+	return l
+}
+
+type concurrencyWrapper struct {
+	client.Client
+	newLimiter func() limiter.Limiter
+	limiters   map[string]limiter.Limiter
+	opts       Options
+	sync.Mutex
+}
+
+// NewClientConcurrencyWrapper create new client wrapper that limits concurrent calls, streams and publications
+// by limiter returned by fn, like limiter.NewAIMD or limiter.NewGradient, request rejected with errors.TooManyRequests.
+// Stream holds slot until closed, its latency measured till stream opened. Publications limited per topic.
+func NewClientConcurrencyWrapper(fn func() limiter.Limiter, opts ...Option) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &concurrencyWrapper{
+			Client:     c,
+			newLimiter: fn,
+			limiters:   make(map[string]limiter.Limiter),
+			opts:       NewOptions(opts...),
+		}
+	}
+}
+
+func (w *concurrencyWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	l, err := w.acquire(w.opts.labels(req.Service(), req.Endpoint()))
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now()
+	err = w.Client.Call(ctx, req, rsp, opts...)
+	release(l, time.Since(ts), err)
+
+	return err
+}
+
+func (w *concurrencyWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	l, err := w.acquire(w.opts.labels(req.Service(), req.Endpoint()))
+	if err != nil {
+		return nil, err
+	}
+
+	ts := time.Now()
+	st, err := w.Client.Stream(ctx, req, opts...)
+	if err != nil || l == nil {
+		release(l, time.Since(ts), err)
+		return st, err
+	}
+
+	return &limitStream{Stream: st, limiter: l, latency: time.Since(ts)}, nil
+}
+
+func (w *concurrencyWrapper) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	l, err := w.acquire(w.opts.topicLabels(msg.Topic()))
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now()
+	err = w.Client.Publish(ctx, msg, opts...)
+	release(l, time.Since(ts), err)
+
+	return err
+}
+
+func (w *concurrencyWrapper) BatchPublish(ctx context.Context, msgs []client.Message, opts ...client.PublishOption) error {
+	ts := time.Now()
+	ls := make([]limiter.Limiter, 0, len(msgs))
+	topics := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		if topics[msg.Topic()] {
+			continue
+		}
+		topics[msg.Topic()] = true
+		l, err := w.acquire(w.opts.topicLabels(msg.Topic()))
+		if err != nil {
+			// batch not sent, so slots freed without overload
+			for _, l := range ls {
+				release(l, time.Since(ts), nil)
+			}
+			return err
+		}
+		ls = append(ls, l)
+	}
+
+	err := w.Client.BatchPublish(ctx, msgs, opts...)
+	for _, l := range ls {
+		release(l, time.Since(ts), err)
+	}
+
+	return err
+}
+
+// acquire takes slot of limiter used for labels, nil limiter returned for nil labels
+func (w *concurrencyWrapper) acquire(labels []string) (limiter.Limiter, error) {
+	if labels == nil {
+		return nil, nil
+	}
+	l := w.limiter(labels)
+	if !l.Acquire() {
+		w.opts.Meter.Counter(ClientRequestRejectedTotal, labels...).Inc()
+		return nil, errors.TooManyRequests("go.micro.client", "concurrency limit exceeded for %s", labels[1])
+	}
+	return l, nil
+}
+
+// release frees slot of the limiter if any
+func release(l limiter.Limiter, latency time.Duration, err error) {
+	if l != nil {
+		l.Release(latency, dropped(err))
+	}
+}
+
+func (w *concurrencyWrapper) limiter(labels []string) limiter.Limiter {
+	w.Lock()
+	defer w.Unlock()
+
+	key := limiterKey(labels)
+	l, ok := w.limiters[key]
+	if !ok {
+		l = w.newLimiter()
+		w.limiters[key] = l
+		w.opts.Meter.Gauge(ClientConcurrencyLimit, func() float64 { return float64(l.Limit()) }, labels...)
+		w.opts.Meter.Gauge(ClientConcurrencyInflight, func() float64 { return float64(l.Inflight()) }, labels...)
+	}
+
+	return l
+}
+
+// limitStream frees concurrency limiter slot on close
+type limitStream struct {
+	client.Stream
+	limiter limiter.Limiter
+	latency time.Duration
+	once    sync.Once
+}
+
+func (s *limitStream) Close() error {
+	err := s.Stream.Close()
+	s.once.Do(func() {
+		release(s.limiter, s.latency, s.Stream.Error())
+	})
+	return err
+}
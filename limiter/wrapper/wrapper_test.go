@@ -0,0 +1,140 @@
+package wrapper
+
+import (
+	"context"
+	"testing"
+
+	"go.unistack.org/micro/v3/client"
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/limiter"
+)
+
+type testClient struct {
+	client.Client
+	call func() error
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return c.call()
+}
+
+func (c *testClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return &testStream{}, c.call()
+}
+
+func (c *testClient) Publish(ctx context.Context, msg client.Message, opts ...client.PublishOption) error {
+	return c.call()
+}
+
+type testStream struct {
+	client.Stream
+}
+
+func (s *testStream) Close() error {
+	return nil
+}
+
+func (s *testStream) Error() error {
+	return nil
+}
+
+func TestClientRateWrapper(t *testing.T) {
+	base := &testClient{Client: client.NewClient(), call: func() error { return nil }}
+	c := NewClientRateWrapper(0, 2, PerEndpoint(true))(base)
+
+	req := c.NewRequest("test", "Test.Call", nil)
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.TODO(), req, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Call(context.TODO(), req, nil); !errors.CodeIn(err, 429) {
+		t.Fatalf("call over rate must be rejected, got %v", err)
+	}
+	if err := c.Call(context.TODO(), c.NewRequest("test", "Test.Other", nil), nil); err != nil {
+		t.Fatalf("other endpoint must have own limiter, got %v", err)
+	}
+	if err := c.Call(context.TODO(), c.NewRequest("test", "Health.Live", nil), nil); err != nil {
+		t.Fatalf("skipped endpoint must not be limited, got %v", err)
+	}
+}
+
+func TestClientConcurrencyWrapper(t *testing.T) {
+	started := make(chan struct{})
+	done := make(chan struct{})
+	base := &testClient{Client: client.NewClient(), call: func() error {
+		started <- struct{}{}
+		<-done
+		return nil
+	}}
+	c := NewClientConcurrencyWrapper(func() limiter.Limiter {
+		return limiter.NewAIMD(limiter.InitialLimit(1), limiter.MaxLimit(1))
+	})(base)
+
+	req := c.NewRequest("test", "Test.Call", nil)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Call(context.TODO(), req, nil)
+	}()
+	<-started
+
+	if err := c.Call(context.TODO(), req, nil); !errors.CodeIn(err, 429) {
+		t.Fatalf("call over concurrency limit must be rejected, got %v", err)
+	}
+
+	close(done)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRateWrapperPublish(t *testing.T) {
+	base := &testClient{Client: client.NewClient(), call: func() error { return nil }}
+	c := NewClientRateWrapper(0, 1)(base)
+
+	msg := c.NewMessage("test", nil)
+	if err := c.Publish(context.TODO(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(context.TODO(), msg); !errors.CodeIn(err, 429) {
+		t.Fatalf("publish over rate must be rejected, got %v", err)
+	}
+	// topic limiter not shared with service of the same name
+	if _, err := c.Stream(context.TODO(), c.NewRequest("test", "Test.Stream", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Stream(context.TODO(), c.NewRequest("test", "Test.Stream", nil)); !errors.CodeIn(err, 429) {
+		t.Fatalf("stream over rate must be rejected, got %v", err)
+	}
+}
+
+func TestClientConcurrencyWrapperStream(t *testing.T) {
+	base := &testClient{Client: client.NewClient(), call: func() error { return nil }}
+	c := NewClientConcurrencyWrapper(func() limiter.Limiter {
+		return limiter.NewAIMD(limiter.InitialLimit(1), limiter.MaxLimit(1))
+	})(base)
+
+	req := c.NewRequest("test", "Test.Stream", nil)
+	st, err := c.Stream(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Stream(context.TODO(), req); !errors.CodeIn(err, 429) {
+		t.Fatalf("stream over concurrency limit must be rejected, got %v", err)
+	}
+	if err = c.Call(context.TODO(), req, nil); !errors.CodeIn(err, 429) {
+		t.Fatalf("call over concurrency limit must be rejected, got %v", err)
+	}
+
+	// slot freed once on close
+	if err = st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = st.Close()
+	if err = c.Call(context.TODO(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Publish(context.TODO(), c.NewMessage("test", nil)); err != nil {
+		t.Fatal(err)
+	}
+}
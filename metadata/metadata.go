@@ -15,10 +15,22 @@ var (
 	HeaderEndpoint = "Micro-Endpoint"
 	// HeaderService specifies service
 	HeaderService = "Micro-Service"
-	// HeaderTimeout specifies timeout of operation
+	// HeaderTimeout specifies timeout of operation in time.Duration format
 	HeaderTimeout = "Micro-Timeout"
 	// HeaderAuthorization specifies Authorization header
 	HeaderAuthorization = "Authorization"
+	// HeaderVersion specifies service version that must handle the request
+	HeaderVersion = "Micro-Version"
+	// HeaderCorrelationID specifies id used to match related messages
+	HeaderCorrelationID = "Micro-Correlation-Id"
+	// HeaderIdempotencyKey specifies key used by service to detect repeated requests
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderRetryAfter specifies seconds or http date after which failed request can be retried
+	HeaderRetryAfter = "Retry-After"
+	// HeaderCaller specifies name of the service or client that sent the request
+	HeaderCaller = "Micro-Caller"
+	// HeaderPriority specifies request priority used by load shedding
+	HeaderPriority = "Micro-Priority"
 )
 
 // Metadata is our way of representing request headers internally.
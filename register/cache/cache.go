@@ -0,0 +1,311 @@
+// Package cache provides a register wrapper that caches lookups
+package cache // import "go.unistack.org/micro/v3/register/cache"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.unistack.org/micro/v3/logger"
+	"go.unistack.org/micro/v3/register"
+	"go.unistack.org/micro/v3/util/backoff"
+	util "go.unistack.org/micro/v3/util/register"
+)
+
+// DefaultTTL is the default ttl of cached services
+var DefaultTTL = time.Minute
+
+// Options holds cache options
+type Options struct {
+	// TTL specifies how long cached services considered fresh
+	TTL time.Duration
+}
+
+// Option func signature
+type Option func(*Options)
+
+// NewOptions returns options that filled by opts
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		TTL: DefaultTTL,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return options
+}
+
+// TTL sets the cache ttl
+func TTL(td time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = td
+	}
+}
+
+// Cache is the register cache interface
+type Cache interface {
+	// Register embed the register interface
+	register.Register
+	// Stop the cache watchers
+	Stop()
+}
+
+type cache struct {
+	r        register.Register
+	services map[string][]*register.Service
+	ttls     map[string]time.Time
+	watched  map[string]bool
+	exit     chan struct{}
+	opts     Options
+	sync.RWMutex
+}
+
+// NewCache returns a new register that caches lookups of the given register.
+// Cached services are kept up to date by register watch, when lookup
+// in underlying register fails stale services returned from cache.
+func NewCache(r register.Register, opts ...Option) Cache {
+	return &cache{
+		r:        r,
+		opts:     NewOptions(opts...),
+		services: make(map[string][]*register.Service),
+		ttls:     make(map[string]time.Time),
+		watched:  make(map[string]bool),
+		exit:     make(chan struct{}),
+	}
+}
+
+func cacheKey(domain, service string) string {
+	return domain + "/" + service
+}
+
+func (c *cache) quit() bool {
+	select {
+	case <-c.exit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *cache) isValid(services []*register.Service, ttl time.Time) bool {
+	// no services exist
+	if len(services) == 0 {
+		return false
+	}
+
+	// ttl is invalid
+	if ttl.IsZero() {
+		return false
+	}
+
+	// time since ttl is longer than timeout
+	if time.Since(ttl) > 0 {
+		return false
+	}
+
+	// ok
+	return true
+}
+
+func (c *cache) get(ctx context.Context, domain string, service string, opts ...register.LookupOption) ([]*register.Service, error) {
+	key := cacheKey(domain, service)
+
+	c.RLock()
+	services := c.services[key]
+	ttl := c.ttls[key]
+	// got services and within ttl so return a copy
+	if c.isValid(services, ttl) {
+		cp := util.Copy(services)
+		c.RUnlock()
+		return cp, nil
+	}
+	c.RUnlock()
+
+	services, err := c.r.LookupService(ctx, service, opts...)
+	if err != nil {
+		// serve stale services if we have it
+		if err != register.ErrNotFound {
+			c.RLock()
+			stale := c.services[key]
+			c.RUnlock()
+			if len(stale) > 0 {
+				if l := c.r.Options().Logger; l.V(logger.DebugLevel) {
+					l.Debugf(ctx, "register cache returns stale services for %s: %v", service, err)
+				}
+				return util.Copy(stale), nil
+			}
+		}
+		return nil, err
+	}
+
+	c.Lock()
+	c.set(key, util.Copy(services))
+	if !c.watched[key] && !c.quit() {
+		c.watched[key] = true
+		go c.run(domain, service)
+	}
+	c.Unlock()
+
+	return services, nil
+}
+
+// set must be called under lock
+func (c *cache) set(key string, services []*register.Service) {
+	if len(services) == 0 {
+		delete(c.services, key)
+		delete(c.ttls, key)
+		return
+	}
+	c.services[key] = services
+	c.ttls[key] = time.Now().Add(c.opts.TTL)
+}
+
+func (c *cache) update(domain string, res *register.Result) {
+	if res == nil || res.Service == nil {
+		return
+	}
+
+	key := cacheKey(domain, res.Service.Name)
+
+	c.Lock()
+	defer c.Unlock()
+
+	// only keep services that was looked up
+	services, ok := c.services[key]
+	if !ok {
+		return
+	}
+
+	switch res.Action {
+	case "create", "update":
+		c.set(key, util.Merge(services, []*register.Service{res.Service}))
+	case "delete":
+		c.set(key, util.Remove(services, []*register.Service{res.Service}))
+	}
+}
+
+// run starts the watcher for service in domain and restarts it on errors
+func (c *cache) run(domain string, service string) {
+	key := cacheKey(domain, service)
+
+	defer func() {
+		c.Lock()
+		delete(c.watched, key)
+		c.Unlock()
+	}()
+
+	var attempts int
+
+	for {
+		if c.quit() {
+			return
+		}
+
+		w, err := c.r.Watch(context.Background(), register.WatchService(service), register.WatchDomain(domain))
+		if err == nil {
+			attempts = 0
+			err = c.watch(domain, w)
+		}
+
+		if c.quit() {
+			return
+		}
+
+		// expire cached services to force lookup on next request
+		c.Lock()
+		c.ttls[key] = time.Time{}
+		c.Unlock()
+
+		attempts++
+		if l := c.r.Options().Logger; l.V(logger.DebugLevel) {
+			l.Debugf(context.Background(), "register cache watcher for %s failed: %v", service, err)
+		}
+
+		select {
+		case <-time.After(backoff.Do(attempts)):
+		case <-c.exit:
+			return
+		}
+	}
+}
+
+func (c *cache) watch(domain string, w register.Watcher) error {
+	// stop watcher on cache stop
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-c.exit:
+			w.Stop()
+		case <-done:
+			w.Stop()
+		}
+	}()
+
+	for {
+		res, err := w.Next()
+		if err != nil {
+			return err
+		}
+		c.update(domain, res)
+	}
+}
+
+func (c *cache) Name() string {
+	return c.r.Name()
+}
+
+func (c *cache) Init(opts ...register.Option) error {
+	return c.r.Init(opts...)
+}
+
+func (c *cache) Options() register.Options {
+	return c.r.Options()
+}
+
+func (c *cache) Connect(ctx context.Context) error {
+	return c.r.Connect(ctx)
+}
+
+func (c *cache) Register(ctx context.Context, s *register.Service, opts ...register.RegisterOption) error {
+	return c.r.Register(ctx, s, opts...)
+}
+
+func (c *cache) Deregister(ctx context.Context, s *register.Service, opts ...register.DeregisterOption) error {
+	return c.r.Deregister(ctx, s, opts...)
+}
+
+func (c *cache) ListServices(ctx context.Context, opts ...register.ListOption) ([]*register.Service, error) {
+	return c.r.ListServices(ctx, opts...)
+}
+
+func (c *cache) Watch(ctx context.Context, opts ...register.WatchOption) (register.Watcher, error) {
+	return c.r.Watch(ctx, opts...)
+}
+
+func (c *cache) LookupService(ctx context.Context, service string, opts ...register.LookupOption) ([]*register.Service, error) {
+	options := register.NewLookupOptions(opts...)
+	return c.get(ctx, options.Domain, service, opts...)
+}
+
+func (c *cache) Disconnect(ctx context.Context) error {
+	c.Stop()
+	return c.r.Disconnect(ctx)
+}
+
+func (c *cache) Stop() {
+	c.Lock()
+	defer c.Unlock()
+
+	select {
+	case <-c.exit:
+		return
+	default:
+		close(c.exit)
+	}
+}
+
+func (c *cache) String() string {
+	return "cache"
+}
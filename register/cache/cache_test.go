@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/register"
+)
+
+type base = register.Register
+
+type failRegister struct {
+	base
+	fail bool
+}
+
+func (r *failRegister) LookupService(ctx context.Context, name string, opts ...register.LookupOption) ([]*register.Service, error) {
+	if r.fail {
+		return nil, errors.New("register unavailable")
+	}
+	return r.base.LookupService(ctx, name, opts...)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	r := &failRegister{base: register.NewRegister()}
+
+	if err := r.Register(ctx, &register.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "foo-1", Address: "localhost:9999"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache(r, TTL(time.Hour))
+	defer c.Stop()
+
+	services, err := c.LookupService(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 1 {
+		t.Fatalf("invalid lookup result %+v", services)
+	}
+
+	// wait for watcher start
+	time.Sleep(50 * time.Millisecond)
+
+	if err = r.Register(ctx, &register.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "foo-2", Address: "localhost:8888"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for watcher event
+	time.Sleep(50 * time.Millisecond)
+
+	r.fail = true
+	services, err = c.LookupService(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("cache not updated by watcher %+v", services)
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	ctx := context.Background()
+	r := &failRegister{base: register.NewRegister()}
+
+	if err := r.Register(ctx, &register.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "foo-1", Address: "localhost:9999"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache(r, TTL(time.Nanosecond))
+	defer c.Stop()
+
+	if _, err := c.LookupService(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.fail = true
+	services, err := c.LookupService(ctx, "foo")
+	if err != nil {
+		t.Fatalf("stale services must be returned: %v", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("invalid lookup result %+v", services)
+	}
+}
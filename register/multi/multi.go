@@ -0,0 +1,260 @@
+// Package multi provides a register that aggregates several registers
+package multi // import "go.unistack.org/micro/v3/register/multi"
+
+import (
+	"context"
+	"sync"
+
+	"go.unistack.org/micro/v3/metadata"
+	"go.unistack.org/micro/v3/register"
+	util "go.unistack.org/micro/v3/util/register"
+)
+
+type multiRegister struct {
+	regs []register.Register
+	opts register.Options
+}
+
+// NewRegister returns a register that fans out Register and Deregister
+// calls to all of the given registers and merges their lookup results.
+// Options applied to the multi register only, child registers must be
+// configured separately.
+func NewRegister(regs []register.Register, opts ...register.Option) register.Register {
+	return &multiRegister{
+		regs: regs,
+		opts: register.NewOptions(opts...),
+	}
+}
+
+// each calls fn for every register in parallel and returns the first error
+func (m *multiRegister) each(fn func(register.Register) error) error {
+	errs := make([]error, len(m.regs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(m.regs))
+	for i, r := range m.regs {
+		go func(i int, r register.Register) {
+			errs[i] = fn(r)
+			wg.Done()
+		}(i, r)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collect calls fn for every register in parallel and returns all results.
+// Registers that returns errors are skipped if at least one register succeed.
+func (m *multiRegister) collect(fn func(register.Register) ([]*register.Service, error)) ([][]*register.Service, error) {
+	results := make([][]*register.Service, len(m.regs))
+	errs := make([]error, len(m.regs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(m.regs))
+	for i, r := range m.regs {
+		go func(i int, r register.Register) {
+			results[i], errs[i] = fn(r)
+			wg.Done()
+		}(i, r)
+	}
+	wg.Wait()
+
+	var err error
+	var found bool
+	for i := range m.regs {
+		switch {
+		case errs[i] == nil:
+			found = true
+		case errs[i] != register.ErrNotFound && err == nil:
+			err = errs[i]
+		}
+	}
+
+	if found {
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, register.ErrNotFound
+}
+
+// copyService makes a copy of service with own metadata, because registers may modify it
+func copyService(s *register.Service) *register.Service {
+	cp := util.CopyService(s)
+	cp.Metadata = metadata.Copy(s.Metadata)
+	for i, n := range cp.Nodes {
+		n.Metadata = metadata.Copy(s.Nodes[i].Metadata)
+	}
+	return cp
+}
+
+func (m *multiRegister) Name() string {
+	return m.opts.Name
+}
+
+func (m *multiRegister) Init(opts ...register.Option) error {
+	for _, o := range opts {
+		o(&m.opts)
+	}
+	return nil
+}
+
+func (m *multiRegister) Options() register.Options {
+	return m.opts
+}
+
+func (m *multiRegister) Connect(ctx context.Context) error {
+	return m.each(func(r register.Register) error {
+		return r.Connect(ctx)
+	})
+}
+
+func (m *multiRegister) Disconnect(ctx context.Context) error {
+	return m.each(func(r register.Register) error {
+		return r.Disconnect(ctx)
+	})
+}
+
+func (m *multiRegister) Register(ctx context.Context, s *register.Service, opts ...register.RegisterOption) error {
+	return m.each(func(r register.Register) error {
+		return r.Register(ctx, copyService(s), opts...)
+	})
+}
+
+func (m *multiRegister) Deregister(ctx context.Context, s *register.Service, opts ...register.DeregisterOption) error {
+	return m.each(func(r register.Register) error {
+		return r.Deregister(ctx, copyService(s), opts...)
+	})
+}
+
+func (m *multiRegister) LookupService(ctx context.Context, name string, opts ...register.LookupOption) ([]*register.Service, error) {
+	results, err := m.collect(func(r register.Register) ([]*register.Service, error) {
+		return r.LookupService(ctx, name, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*register.Service
+	for _, result := range results {
+		services = util.Merge(services, result)
+	}
+
+	if len(services) == 0 {
+		return nil, register.ErrNotFound
+	}
+
+	return services, nil
+}
+
+func (m *multiRegister) ListServices(ctx context.Context, opts ...register.ListOption) ([]*register.Service, error) {
+	results, err := m.collect(func(r register.Register) ([]*register.Service, error) {
+		return r.ListServices(ctx, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	byName := make(map[string][]*register.Service)
+	for _, result := range results {
+		for _, s := range result {
+			if _, ok := byName[s.Name]; !ok {
+				names = append(names, s.Name)
+			}
+			byName[s.Name] = util.Merge(byName[s.Name], []*register.Service{s})
+		}
+	}
+
+	services := make([]*register.Service, 0, len(names))
+	for _, name := range names {
+		services = append(services, byName[name]...)
+	}
+
+	return services, nil
+}
+
+func (m *multiRegister) Watch(ctx context.Context, opts ...register.WatchOption) (register.Watcher, error) {
+	w := &watcher{
+		res:  make(chan *register.Result),
+		errs: make(chan error, len(m.regs)),
+		exit: make(chan struct{}),
+	}
+
+	for _, r := range m.regs {
+		rw, err := r.Watch(ctx, opts...)
+		if err != nil {
+			w.Stop()
+			return nil, err
+		}
+		w.watchers = append(w.watchers, rw)
+		go w.run(rw)
+	}
+
+	return w, nil
+}
+
+func (m *multiRegister) String() string {
+	return "multi"
+}
+
+type watcher struct {
+	res      chan *register.Result
+	errs     chan error
+	exit     chan struct{}
+	watchers []register.Watcher
+	sync.Mutex
+}
+
+func (w *watcher) run(rw register.Watcher) {
+	for {
+		res, err := rw.Next()
+		if err != nil {
+			select {
+			case w.errs <- err:
+			case <-w.exit:
+			}
+			return
+		}
+		select {
+		case w.res <- res:
+		case <-w.exit:
+			return
+		}
+	}
+}
+
+func (w *watcher) Next() (*register.Result, error) {
+	select {
+	case res := <-w.res:
+		return res, nil
+	case err := <-w.errs:
+		w.Stop()
+		return nil, err
+	case <-w.exit:
+		return nil, register.ErrWatcherStopped
+	}
+}
+
+func (w *watcher) Stop() {
+	w.Lock()
+	defer w.Unlock()
+
+	select {
+	case <-w.exit:
+		return
+	default:
+		close(w.exit)
+		for _, rw := range w.watchers {
+			rw.Stop()
+		}
+	}
+}
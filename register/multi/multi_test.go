@@ -0,0 +1,65 @@
+package multi
+
+import (
+	"context"
+	"testing"
+
+	"go.unistack.org/micro/v3/register"
+)
+
+func TestMultiRegister(t *testing.T) {
+	ctx := context.Background()
+	r1 := register.NewRegister()
+	r2 := register.NewRegister()
+
+	if err := r1.Register(ctx, &register.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "foo-1", Address: "localhost:9999"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Register(ctx, &register.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "foo-2", Address: "localhost:8888"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewRegister([]register.Register{r1, r2})
+
+	services, err := m.LookupService(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("invalid lookup result %+v", services)
+	}
+
+	if err = m.Register(ctx, &register.Service{
+		Name:    "bar",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "bar-1", Address: "localhost:7777"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []register.Register{r1, r2} {
+		if _, err = r.LookupService(ctx, "bar"); err != nil {
+			t.Fatalf("service not registered in %s: %v", r, err)
+		}
+	}
+
+	if err = m.Deregister(ctx, &register.Service{
+		Name:    "bar",
+		Version: "1.0.0",
+		Nodes:   []*register.Node{{ID: "bar-1", Address: "localhost:7777"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.LookupService(ctx, "bar"); err != register.ErrNotFound {
+		t.Fatalf("service must be deregistered, err: %v", err)
+	}
+}
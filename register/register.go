@@ -11,6 +11,10 @@ import (
 const (
 	// WildcardDomain indicates any domain
 	WildcardDomain = "*"
+	// MetadataIdempotent is the endpoint metadata key that marks endpoint safe to call more than once
+	MetadataIdempotent = "idempotent"
+	// MetadataVersion is the node metadata key that holds service version, filled by server on register
+	MetadataVersion = "version"
 )
 
 // DefaultDomain to use if none was provided in options
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/metadata"
+)
+
+// NewDeadlineContext returns handler context with deadline derived from metadata.HeaderTimeout of request metadata,
+// timeout bounded by max if max > 0. Request without timeout gets max timeout, exhausted timeout gives expired context.
+func NewDeadlineContext(ctx context.Context, md metadata.Metadata, max time.Duration) (context.Context, context.CancelFunc) {
+	if v, ok := md.Get(metadata.HeaderTimeout); ok {
+		if d, err := time.ParseDuration(v); err == nil && (max <= 0 || d < max) {
+			return context.WithTimeout(ctx, d)
+		}
+	}
+	if max <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, max)
+}
+
+// NewDeadlineHandlerWrapper returns handler wrapper that sets handler context deadline by NewDeadlineContext,
+// so nested calls inherit caller budget. Request with exhausted budget rejected with errors.Timeout.
+// Wrapper is not installed by default, pass it via WrapHandler with max to limit caller timeout.
+func NewDeadlineHandlerWrapper(max time.Duration) HandlerWrapper {
+	return func(fn HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req Request, rsp interface{}) error {
+			ctx, cancel := NewDeadlineContext(ctx, req.Header(), max)
+			defer cancel()
+			if ctx.Err() != nil {
+				return errors.Timeout("go.micro.server", "deadline exceeded for %s", req.Endpoint())
+			}
+			return fn(ctx, req, rsp)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.unistack.org/micro/v3/errors"
+	"go.unistack.org/micro/v3/metadata"
+)
+
+type testRequest struct {
+	Request
+	header metadata.Metadata
+}
+
+func (r *testRequest) Header() metadata.Metadata {
+	return r.header
+}
+
+func (r *testRequest) Endpoint() string {
+	return "Test.Call"
+}
+
+func TestNewDeadlineContext(t *testing.T) {
+	md := metadata.Metadata{metadata.HeaderTimeout: (100 * time.Millisecond).String()}
+
+	ctx, cancel := NewDeadlineContext(context.TODO(), md, time.Second)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > 100*time.Millisecond {
+		t.Fatalf("deadline must be derived from header, got %v %v", time.Until(d), ok)
+	}
+
+	ctx, cancel = NewDeadlineContext(context.TODO(), md, 10*time.Millisecond)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > 10*time.Millisecond {
+		t.Fatalf("deadline must be bounded by max, got %v %v", time.Until(d), ok)
+	}
+
+	ctx, cancel = NewDeadlineContext(context.TODO(), metadata.Metadata{metadata.HeaderTimeout: "0s"}, time.Second)
+	defer cancel()
+	if ctx.Err() == nil {
+		t.Fatal("exhausted timeout must give expired context")
+	}
+
+	ctx, cancel = NewDeadlineContext(context.TODO(), metadata.New(0), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("deadline must not be set without header and max")
+	}
+}
+
+func TestDeadlineHandlerWrapper(t *testing.T) {
+	h := NewDeadlineHandlerWrapper(time.Second)(func(ctx context.Context, req Request, rsp interface{}) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatal("handler context must have deadline")
+		}
+		return nil
+	})
+
+	if err := h(context.TODO(), &testRequest{header: metadata.New(0)}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"0s", "-1s"} {
+		req := &testRequest{header: metadata.Metadata{metadata.HeaderTimeout: v}}
+		if err := h(context.TODO(), req, nil); !errors.CodeIn(err, 408) {
+			t.Fatalf("request with exhausted budget %s must be rejected, got %v", v, err)
+		}
+	}
+}
+
+func TestDeadlineHandlerWrapperOptIn(t *testing.T) {
+	if wrappers := NewOptions().HdlrWrappers; len(wrappers) != 0 {
+		t.Fatal("deadline wrapper must not be installed by default")
+	}
+	wrappers := NewOptions(WrapHandler(NewDeadlineHandlerWrapper(0))).HdlrWrappers
+	h := wrappers[0](func(ctx context.Context, req Request, rsp interface{}) error {
+		if d, ok := ctx.Deadline(); !ok || time.Until(d) > 100*time.Millisecond {
+			t.Fatalf("deadline must be derived from header, got %v %v", time.Until(d), ok)
+		}
+		return nil
+	})
+	req := &testRequest{header: metadata.Metadata{metadata.HeaderTimeout: (100 * time.Millisecond).String()}}
+	if err := h(context.TODO(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+}
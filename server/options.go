@@ -383,6 +383,17 @@ func EndpointMetadata(name string, md metadata.Metadata) HandlerOption {
 	}
 }
 
+// EndpointIdempotent is a Handler option that marks endpoint as idempotent,
+// client sends hedged requests only to idempotent endpoints
+func EndpointIdempotent(name string) HandlerOption {
+	return func(o *HandlerOptions) {
+		if o.Metadata[name] == nil {
+			o.Metadata[name] = metadata.New(1)
+		}
+		o.Metadata[name].Set(register.MetadataIdempotent, "true")
+	}
+}
+
 // DisableAutoAck will disable auto acking of messages
 // after they have been handled.
 func DisableAutoAck() SubscriberOption {
@@ -80,6 +80,7 @@ func NewRegisterService(s Server) (*register.Service, error) {
 	node.Metadata["server"] = s.String()
 	node.Metadata["broker"] = opts.Broker.String()
 	node.Metadata["register"] = opts.Register.String()
+	node.Metadata[register.MetadataVersion] = opts.Version
 
 	return &register.Service{
 		Name:     opts.Name,
@@ -93,31 +93,21 @@ func Copy(current []*register.Service) []*register.Service {
 
 // Merge merges two lists of services and returns a new copy
 func Merge(olist []*register.Service, nlist []*register.Service) []*register.Service {
-	var srv []*register.Service
+	srv := Copy(olist)
 
 	for _, n := range nlist {
 		var seen bool
-		for _, o := range olist {
-			if o.Version == n.Version {
-				sp := &register.Service{}
-				// make copy
-				*sp = *o
+		for _, sp := range srv {
+			if sp.Version == n.Version {
 				// set nodes
-				sp.Nodes = addNodes(o.Nodes, n.Nodes)
-
+				sp.Nodes = addNodes(sp.Nodes, n.Nodes)
 				// mark as seen
 				seen = true
-				srv = append(srv, sp)
 				break
-			} else {
-				sp := &register.Service{}
-				// make copy
-				*sp = *o
-				srv = append(srv, sp)
 			}
 		}
 		if !seen {
-			srv = append(srv, Copy([]*register.Service{n})...)
+			srv = append(srv, CopyService(n))
 		}
 	}
 	return srv
@@ -76,3 +76,43 @@ func TestRemoveNodes(t *testing.T) {
 		t.Logf("Nodes %+v", nodes)
 	}
 }
+
+func TestMerge(t *testing.T) {
+	olist := []*register.Service{
+		{
+			Name:    "foo",
+			Version: "1.0.0",
+			Nodes:   []*register.Node{{ID: "foo-1.0.0-123", Address: "localhost:9999"}},
+		},
+		{
+			Name:    "foo",
+			Version: "1.0.1",
+			Nodes:   []*register.Node{{ID: "foo-1.0.1-123", Address: "localhost:6666"}},
+		},
+	}
+	nlist := []*register.Service{
+		{
+			Name:    "foo",
+			Version: "1.0.0",
+			Nodes:   []*register.Node{{ID: "foo-1.0.0-321", Address: "localhost:8888"}},
+		},
+		{
+			Name:    "foo",
+			Version: "1.0.2",
+			Nodes:   []*register.Node{{ID: "foo-1.0.2-123", Address: "localhost:7777"}},
+		},
+	}
+
+	servs := Merge(olist, nlist)
+	if i := len(servs); i != 3 {
+		t.Fatalf("Expected 3 services, got %d: %+v", i, servs)
+	}
+	for _, s := range servs {
+		if s.Version == "1.0.0" && len(s.Nodes) != 2 {
+			t.Fatalf("Expected 2 nodes for version 1.0.0, got %d: %+v", len(s.Nodes), s.Nodes)
+		}
+	}
+	if len(olist[0].Nodes) != 1 {
+		t.Fatalf("Merge must not modify source list: %+v", olist[0].Nodes)
+	}
+}